@@ -1,39 +1,189 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/cache"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/nominatim"
 	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/config"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/geocoding"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/heatmap"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/metrics"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
 	"github.com/hugocorbucci/onde-2a-dose-backend/internal/server"
-)
-
-const (
-	defaultPort = "8080"
-	fetchTimeout = 10 * time.Second
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/storage"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/webhooks"
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.New(os.Stderr, "Onde2aDose - ", 0).Fatal(err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.New(os.Stderr, "Onde2aDose - ", 0).Fatal(err)
+		}
+		return
+	}
+
+	// Errors are always logged. Other messages depend on the log level
 	ll := log.New(os.Stdout, "Onde2aDose - ", 0)
-	port := os.Getenv("PORT")
-	if len(port) == 0 {
-		port = defaultPort
+	info := ll
+	if !cfg.Logs(config.LogLevelInfo) {
+		info = log.New(ioutil.Discard, "", 0)
+	}
+	addr := net.JoinHostPort("", cfg.Port)
+
+	httpClient, err := httpclient.New(cfg.HTTPClient)
+	if err != nil {
+		ll.Fatal("could not create HTTP client: ", err)
+	}
+	registry := metrics.NewRegistry()
+	prefeituraClient := &prefeitura.Client{
+		HTTPClient: metrics.NewHTTPClient(httpClient, registry),
+		BaseURL:    cfg.UpstreamURL,
+		Retry:      cfg.Retry,
+	}
+	// The URL was validated when loading the configuration
+	upstream, _ := url.Parse(cfg.UpstreamURL)
+	info.Println("Fetching De Olho Na Fila data from", upstream.Redacted())
+	geocoder := &nominatim.Client{HTTPClient: httpClient}
+
+	geocodeStore, err := storage.NewGeocodeFileStore(cfg.GeocodesPath)
+	if err != nil {
+		ll.Fatal("could not load geocodes from ", cfg.GeocodesPath, ": ", err)
+	}
+
+	circuitBreaker := breaker.NewDeOlhoNaFila(prefeituraClient, cfg.Breaker)
+	// Measured outside the breaker so fetches refused by an open circuit are counted
+	var source deps.DeOlhoNaFila = metrics.NewDeOlhoNaFila(circuitBreaker, registry)
+	if cfg.Cache.TTL > 0 {
+		source = cache.NewDeOlhoNaFila(source, cfg.Cache)
+	}
+	snapshotStore, err := storage.NewBoltSnapshotStore(cfg.HistoryPath, cfg.HistoryRetention, ll)
+	if err != nil {
+		ll.Fatal("could not open history at ", cfg.HistoryPath, ": ", err)
+	}
+
+	lineHeatmap, err := heatmap.New()
+	if err != nil {
+		ll.Fatal("could not create heatmap: ", err)
+	}
+	err = snapshotStore.Walk(time.Time{}, func(unit *prefeituradeps.DeOlhoNaFilaUnit) error {
+		lineHeatmap.Add(unit)
+		return nil
+	})
+	if err != nil {
+		ll.Fatal("could not load history from ", cfg.HistoryPath, ": ", err)
+	}
+
+	// Background work stops only after the HTTP server drained its requests
+	runCtx, stopRunning := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+
+	refresher := poller.New(source, cfg.PollInterval, ll)
+	refresher.SetMaxStale(cfg.MaxStale)
+	refresher.AddListener(recordHistory(snapshotStore, ll))
+	refresher.AddListener(metrics.NewSnapshots(registry).Record)
+	refresher.AddListener(func(_ context.Context, snapshot *poller.Snapshot) {
+		lineHeatmap.Add(snapshot.Units...)
+	})
+	backfill := geocoding.NewBackfill(geocoder, geocodeStore, cfg.GeocodeInterval, ll)
+	refresher.AddListener(backfill.Record)
+	changeLog := changes.NewLog(&changes.Detector{StaleAfter: changes.DefaultStaleAfter}, changes.DefaultRetention)
+	refresher.AddListener(changeLog.Record)
+
+	subscriptionStore, err := storage.NewSubscriptionFileStore(cfg.WebhooksPath)
+	if err != nil {
+		ll.Fatal("could not load webhook subscriptions from ", cfg.WebhooksPath, ": ", err)
+	}
+	// Subscriptions choose where deliveries go so they can't reach internal services
+	webhookClient, err := httpclient.NewPublicOnly(cfg.HTTPClient)
+	if err != nil {
+		ll.Fatal("could not create webhook HTTP client: ", err)
 	}
-	addr := net.JoinHostPort("", port)
+	dispatcher := webhooks.NewDispatcher(subscriptionStore, webhookClient, ll)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		dispatcher.Run(runCtx, changeLog)
+	}()
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		backfill.Run(runCtx)
+	}()
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		refresher.Run(runCtx)
+	}()
 
-	httpClient := http.DefaultClient
-	httpClient.Timeout = fetchTimeout
-	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
+	s := server.NewHTTPServer(refresher, server.WithGeocodeStore(geocodeStore), server.WithCircuitBreaker(circuitBreaker), server.WithSnapshotStore(snapshotStore), server.WithHeatmap(lineHeatmap), server.WithChangeLog(changeLog), server.WithSubscriptionStore(subscriptionStore), server.WithWebhookTokens(cfg.WebhookAdminToken, cfg.WebhookPartners()), server.WithMetrics(registry), server.WithReadiness(refresher, cfg.ReadinessMaxAge), server.WithCORS(cfg.CORSOrigins), server.WithLogger(ll))
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		// Lets event streams replace the write timeout with one per write
+		ConnContext: server.ConnContext,
 	}
-	prefeituraClient := &prefeitura.Client{HTTPClient: httpClient}
+	httpServer.RegisterOnShutdown(s.CloseStreams)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		info.Println("Starting server on port", cfg.Port)
+		serverErr <- httpServer.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serverErr:
+		ll.Fatal("HTTP server failed: ", err)
+	case sig := <-signals:
+		info.Println("Received", sig, "- shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		ll.Println("error draining HTTP requests:", err)
+	}
+	stopRunning()
+	background.Wait()
+	if err := snapshotStore.Close(); err != nil {
+		ll.Println("error closing history:", err)
+	}
+	info.Println("Server stopped")
+}
 
-	ll.Println("Starting server on port", port)
-	s := server.NewHTTPServer(prefeituraClient)
-	if err := http.ListenAndServe(addr, s); err != nil {
-		ll.Fatal("HTTP(s) server failed")
+// recordHistory saves every snapshot fetched by the poller into store
+func recordHistory(store deps.SnapshotStore, ll *log.Logger) poller.Listener {
+	return func(_ context.Context, snapshot *poller.Snapshot) {
+		if _, err := store.Save(snapshot.FetchedAt, snapshot.Units); err != nil {
+			ll.Println("error saving snapshot history:", err)
+		}
 	}
 }
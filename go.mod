@@ -3,7 +3,11 @@ module github.com/hugocorbucci/onde-2a-dose-backend
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/gorilla/mux v1.8.0
 	github.com/maxbrunsfeld/counterfeiter/v6 v6.4.1
+	github.com/prometheus/client_golang v1.10.0
 	github.com/stretchr/testify v1.7.0
+	go.etcd.io/bbolt v1.3.6
+	gopkg.in/yaml.v3 v3.0.1
 )
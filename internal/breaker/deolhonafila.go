@@ -0,0 +1,197 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// Closed lets every call through to the source
+	Closed State = iota
+	// Open fails calls fast without reaching the source
+	Open
+	// HalfOpen lets a single trial call through to decide whether to close or reopen
+	HalfOpen
+)
+
+var (
+	// ErrOpen is returned while the circuit is open. It is wrapped by StaleError when there is a previous result.
+	ErrOpen = errors.New("circuit breaker is open")
+)
+
+// StaleError is returned while the circuit is open and carries the last successful result, so
+// callers can tell it apart from fresh data
+type StaleError struct {
+	Units []*prefeitura.DeOlhoNaFilaUnit
+	// FetchedAt is when Units were fetched from the source
+	FetchedAt time.Time
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%v: last result fetched at %s", ErrOpen, e.FetchedAt.Format(time.RFC3339))
+}
+
+// Unwrap returns ErrOpen
+func (e *StaleError) Unwrap() error {
+	return ErrOpen
+}
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// MarshalText encodes the state by its name
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Settings configures when the circuit opens and how long it stays open
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// ResetTimeout is how long the circuit stays open before a trial call is allowed
+	ResetTimeout time.Duration
+}
+
+// DefaultSettings are sensible settings for the city hall endpoint
+var DefaultSettings = Settings{
+	FailureThreshold: 5,
+	ResetTimeout:     30 * time.Second,
+}
+
+// Status is a point in time view of the circuit breaker
+type Status struct {
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+}
+
+// DeOlhoNaFila is a circuit breaker in front of another dependencies.DeOlhoNaFila.
+// While the circuit is open it fails fast with a StaleError holding the last successful result or with ErrOpen.
+type DeOlhoNaFila struct {
+	source   deps.DeOlhoNaFila
+	settings Settings
+
+	mutex         sync.Mutex
+	state         State
+	failures      int
+	openedAt      time.Time
+	lastErr       error
+	lastGood      []*prefeitura.DeOlhoNaFilaUnit
+	lastSuccessAt time.Time
+	trialRunning  bool
+}
+
+// NewDeOlhoNaFila creates a closed circuit breaker in front of source
+func NewDeOlhoNaFila(source deps.DeOlhoNaFila, settings Settings) *DeOlhoNaFila {
+	return &DeOlhoNaFila{source: source, settings: settings}
+}
+
+// Fetch calls the source unless the circuit is open
+func (b *DeOlhoNaFila) Fetch(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	if !b.allow() {
+		return b.fallback()
+	}
+
+	units, err := b.source.Fetch(ctx)
+	b.record(ctx, units, err)
+	return units, err
+}
+
+// Status returns the current state of the circuit breaker
+func (b *DeOlhoNaFila) Status() Status {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := Status{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != Closed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.lastErr != nil {
+		status.LastError = b.lastErr.Error()
+	}
+	if !b.lastSuccessAt.IsZero() {
+		lastSuccessAt := b.lastSuccessAt
+		status.LastSuccessAt = &lastSuccessAt
+	}
+	return status
+}
+
+func (b *DeOlhoNaFila) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.settings.ResetTimeout {
+			return false
+		}
+		b.state = HalfOpen
+		b.trialRunning = true
+		return true
+	case HalfOpen:
+		if b.trialRunning {
+			return false
+		}
+		b.trialRunning = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *DeOlhoNaFila) record(ctx context.Context, units []*prefeitura.DeOlhoNaFilaUnit, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trialRunning = false
+
+	if err == nil {
+		b.state = Closed
+		b.failures = 0
+		b.lastErr = nil
+		b.lastGood = units
+		b.lastSuccessAt = time.Now()
+		return
+	}
+	if ctx.Err() != nil {
+		// The caller gave up, which says nothing about the health of the source
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == HalfOpen || b.failures >= b.settings.FailureThreshold {
+		b.state = Open
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *DeOlhoNaFila) fallback() ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.lastGood == nil {
+		return nil, ErrOpen
+	}
+	return nil, &StaleError{Units: b.lastGood, FetchedAt: b.lastSuccessAt}
+}
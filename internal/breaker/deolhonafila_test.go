@@ -0,0 +1,135 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.DeOlhoNaFila = &breaker.DeOlhoNaFila{}
+
+func TestDeOlhoNaFila_OpensAfterConsecutiveFailures(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns(nil, errors.New("boom"))
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 2, ResetTimeout: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		_, err := b.Fetch(context.Background())
+		require.EqualError(t, err, "boom", "expected source error while closed")
+	}
+	assert.Equal(t, breaker.Open, b.Status().State, "expected state to match")
+
+	_, err := b.Fetch(context.Background())
+	require.ErrorIs(t, err, breaker.ErrOpen, "expected fast failure while open")
+	assert.Equal(t, 2, source.FetchCallCount(), "expected source not to be called while open")
+}
+
+func TestDeOlhoNaFila_ReturnsLastGoodResultAsStaleWhileOpen(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturns(nil, errors.New("boom"))
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: time.Hour})
+
+	_, err := b.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	fetchedAt := *b.Status().LastSuccessAt
+	_, err = b.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+
+	units, err := b.Fetch(context.Background())
+	assert.Nil(t, units, "expected no fresh units while open")
+	require.ErrorIs(t, err, breaker.ErrOpen, "expected error to match")
+	var staleErr *breaker.StaleError
+	require.ErrorAs(t, err, &staleErr, "expected last good result while open")
+	assert.Equal(t, "1", staleErr.Units[0].IDStr, "expected last good result to match")
+	assert.Equal(t, fetchedAt, staleErr.FetchedAt, "expected last good result to keep its fetch time")
+	assert.Equal(t, 2, source.FetchCallCount(), "expected source not to be called while open")
+}
+
+func TestDeOlhoNaFila_ClosesAfterSuccessfulTrial(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, nil, errors.New("boom"))
+	source.FetchReturnsOnCall(1, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: 5 * time.Millisecond})
+
+	_, err := b.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	require.Equal(t, breaker.Open, b.Status().State, "expected state to match")
+
+	time.Sleep(10 * time.Millisecond)
+	_, err = b.Fetch(context.Background())
+	require.NoError(t, err, "expected trial call to reach the source")
+	status := b.Status()
+	assert.Equal(t, breaker.Closed, status.State, "expected state to match")
+	assert.Equal(t, 0, status.ConsecutiveFailures, "expected failures to be reset")
+	assert.NotNil(t, status.LastSuccessAt, "expected last success to be set")
+}
+
+func TestDeOlhoNaFila_ReopensAfterFailedTrial(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns(nil, errors.New("boom"))
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: 5 * time.Millisecond})
+
+	_, err := b.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = b.Fetch(context.Background())
+	require.EqualError(t, err, "boom", "expected trial call to reach the source")
+	status := b.Status()
+	assert.Equal(t, breaker.Open, status.State, "expected state to match")
+	assert.Equal(t, "boom", status.LastError, "expected last error to match")
+
+	_, err = b.Fetch(context.Background())
+	require.ErrorIs(t, err, breaker.ErrOpen, "expected fast failure after reopening")
+	assert.Equal(t, 2, source.FetchCallCount(), "expected call count to match")
+}
+
+func TestDeOlhoNaFila_AllowsASingleTrialWhileHalfOpen(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	source.FetchReturnsOnCall(0, nil, errors.New("boom"))
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: 5 * time.Millisecond})
+
+	_, err := b.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	time.Sleep(10 * time.Millisecond)
+
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-release
+		return []*prefeitura.DeOlhoNaFilaUnit{}, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		b.Fetch(context.Background())
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 2 }, time.Second, time.Millisecond, "expected trial call")
+	assert.Equal(t, breaker.HalfOpen, b.Status().State, "expected state to match")
+
+	_, err = b.Fetch(context.Background())
+	require.ErrorIs(t, err, breaker.ErrOpen, "expected concurrent calls to fail fast during trial")
+	close(release)
+	<-done
+	assert.Equal(t, breaker.Closed, b.Status().State, "expected state to match")
+}
+
+func TestDeOlhoNaFila_IgnoresCallerCancellation(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns(nil, context.Canceled)
+	b := breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.Fetch(ctx)
+	require.Error(t, err, "expected error to match")
+	assert.Equal(t, breaker.Closed, b.Status().State, "expected state to match")
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+// Settings controls how long cached data is served
+type Settings struct {
+	// TTL is how long fetched data is considered fresh
+	TTL time.Duration
+	// MaxStale is how long after the TTL stale data is still served while it is refreshed. Past it,
+	// callers wait for the refresh and get its error if it fails.
+	MaxStale time.Duration
+	// RefreshTimeout bounds each upstream call
+	RefreshTimeout time.Duration
+}
+
+// DefaultSettings are reasonable settings for the De Olho Na Fila API
+var DefaultSettings = Settings{
+	TTL:            30 * time.Second,
+	MaxStale:       5 * time.Minute,
+	RefreshTimeout: 30 * time.Second,
+}
+
+// DeOlhoNaFila caches the units returned by another dependencies.DeOlhoNaFila.
+// Data older than the TTL is still served, up to MaxStale, while a single background refresh runs
+// and concurrent callers waiting on an empty or expired cache share the same upstream call.
+type DeOlhoNaFila struct {
+	source   deps.DeOlhoNaFila
+	settings Settings
+
+	mutex     sync.Mutex
+	units     []*prefeitura.DeOlhoNaFilaUnit
+	fetchedAt time.Time
+	loaded    bool
+	inflight  *fetchCall
+}
+
+type fetchCall struct {
+	done  chan struct{}
+	units []*prefeitura.DeOlhoNaFilaUnit
+	err   error
+}
+
+// NewDeOlhoNaFila creates a cache in front of source
+func NewDeOlhoNaFila(source deps.DeOlhoNaFila, settings Settings) *DeOlhoNaFila {
+	return &DeOlhoNaFila{source: source, settings: settings}
+}
+
+// Fetch returns cached units, refreshing them in the background when stale or waiting for the
+// upstream call when nothing usable has been cached
+func (c *DeOlhoNaFila) Fetch(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	c.mutex.Lock()
+	call := c.inflight
+	if c.loaded {
+		age := time.Since(c.fetchedAt)
+		if age >= c.settings.TTL && call == nil {
+			call = c.startFetch()
+		}
+		if age < c.settings.TTL+c.settings.MaxStale {
+			units := c.units
+			c.mutex.Unlock()
+			return units, nil
+		}
+	}
+	if call == nil {
+		call = c.startFetch()
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.units, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startFetch must be called with the mutex held
+func (c *DeOlhoNaFila) startFetch() *fetchCall {
+	call := &fetchCall{done: make(chan struct{})}
+	c.inflight = call
+
+	go func() {
+		// The upstream call is shared so it can't be bound to a single caller's context
+		ctx, cancel := context.WithTimeout(context.Background(), c.settings.RefreshTimeout)
+		defer cancel()
+		units, err := c.source.Fetch(ctx)
+
+		c.mutex.Lock()
+		if err == nil {
+			c.units = units
+			c.fetchedAt = time.Now()
+			c.loaded = true
+		}
+		c.inflight = nil
+		c.mutex.Unlock()
+
+		call.units, call.err = units, err
+		close(call.done)
+	}()
+	return call
+}
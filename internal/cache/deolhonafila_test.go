@@ -0,0 +1,176 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/cache"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.DeOlhoNaFila = &cache.DeOlhoNaFila{}
+
+func TestDeOlhoNaFila_FetchServesFreshDataWithoutCallingSource(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns([]*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	c := cache.NewDeOlhoNaFila(source, settings(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		units, err := c.Fetch(context.Background())
+		require.NoError(t, err, "expected error to match")
+		assert.Len(t, units, 1, "expected length to match")
+	}
+	assert.Equal(t, 1, source.FetchCallCount(), "expected a single upstream call")
+}
+
+func TestDeOlhoNaFila_FetchServesStaleDataWhileRefreshing(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		if source.FetchCallCount() == 1 {
+			return []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil
+		}
+		<-release
+		return []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "2"}}, nil
+	}
+	c := cache.NewDeOlhoNaFila(source, settings(10*time.Millisecond))
+
+	units, err := c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	require.Equal(t, "1", units[0].IDStr, "expected first fetch to match")
+
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		units, err = c.Fetch(context.Background())
+		require.NoError(t, err, "expected error to match")
+		assert.Equal(t, "1", units[0].IDStr, "expected stale data while refreshing")
+	}
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 2 }, time.Second, time.Millisecond, "expected background refresh")
+	_, err = c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Equal(t, 2, source.FetchCallCount(), "expected a single background refresh")
+
+	close(release)
+	require.Eventually(t, func() bool {
+		units, err := c.Fetch(context.Background())
+		return err == nil && units[0].IDStr == "2"
+	}, time.Second, time.Millisecond, "expected refreshed data to be served")
+}
+
+func TestDeOlhoNaFila_FetchCollapsesConcurrentCallersOnEmptyCache(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-release
+		return []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil
+	}
+	c := cache.NewDeOlhoNaFila(source, settings(time.Hour))
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			units, err := c.Fetch(context.Background())
+			if err == nil {
+				results[i] = len(units)
+			}
+		}(i)
+	}
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 1 }, time.Second, time.Millisecond, "expected upstream call")
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, source.FetchCallCount(), "expected a single upstream call")
+	for i, size := range results {
+		assert.Equal(t, 1, size, "expected caller %d to receive the shared result", i)
+	}
+}
+
+func TestDeOlhoNaFila_FetchReturnsErrorOnEmptyCacheAndRetriesNextTime(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, nil, errors.New("boom"))
+	source.FetchReturnsOnCall(1, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	c := cache.NewDeOlhoNaFila(source, settings(time.Hour))
+
+	_, err := c.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+
+	units, err := c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 1, "expected length to match")
+}
+
+func TestDeOlhoNaFila_FetchKeepsStaleDataWhenRefreshFails(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturns(nil, errors.New("boom"))
+	c := cache.NewDeOlhoNaFila(source, settings(time.Millisecond))
+
+	_, err := c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	require.Eventually(t, func() bool { return source.FetchCallCount() >= 2 }, time.Second, time.Millisecond, "expected refresh")
+
+	units, err := c.Fetch(context.Background())
+	require.NoError(t, err, "expected stale data to be served after refresh failure")
+	assert.Equal(t, "1", units[0].IDStr, "expected stale data to match")
+}
+
+func TestDeOlhoNaFila_FetchReturnsRefreshErrorOnceDataIsTooStale(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturns(nil, errors.New("boom"))
+	c := cache.NewDeOlhoNaFila(source, cache.Settings{TTL: time.Millisecond, MaxStale: 10 * time.Millisecond, RefreshTimeout: time.Second})
+
+	_, err := c.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	time.Sleep(20 * time.Millisecond)
+
+	units, err := c.Fetch(context.Background())
+	require.EqualError(t, err, "boom", "expected refresh error once the data is too stale")
+	assert.Nil(t, units, "expected no units to match")
+}
+
+func TestDeOlhoNaFila_FetchBoundsRefreshByTimeout(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchStub = func(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	c := cache.NewDeOlhoNaFila(source, cache.Settings{TTL: time.Hour, RefreshTimeout: 10 * time.Millisecond})
+
+	_, err := c.Fetch(context.Background())
+	require.ErrorIs(t, err, context.DeadlineExceeded, "expected hung refresh to time out")
+}
+
+func TestDeOlhoNaFila_FetchHonorsCallerCancellationWhileWaiting(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	defer close(release)
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-release
+		return nil, nil
+	}
+	c := cache.NewDeOlhoNaFila(source, settings(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.Fetch(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "expected error to match")
+}
+
+func settings(ttl time.Duration) cache.Settings {
+	return cache.Settings{TTL: ttl, MaxStale: time.Hour, RefreshTimeout: time.Second}
+}
@@ -0,0 +1,131 @@
+package changes
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+)
+
+const (
+	// DefaultStaleAfter is how long a unit can go without updates before it is reported as stale
+	DefaultStaleAfter = 2 * time.Hour
+)
+
+// EventType is the kind of change detected in a unit
+type EventType string
+
+const (
+	EventUnitAdded          EventType = "unit_added"
+	EventUnitRemoved        EventType = "unit_removed"
+	EventStatusChanged      EventType = "status_changed"
+	EventVaccineAvailable   EventType = "vaccine_available"
+	EventVaccineUnavailable EventType = "vaccine_unavailable"
+	EventUnitStale          EventType = "unit_stale"
+)
+
+// Event is a change in a unit between two snapshots
+type Event struct {
+	// ID increases with every event recorded in a Log
+	ID     int64     `json:"id"`
+	Type   EventType `json:"type"`
+	At     time.Time `json:"at"`
+	UnitID int       `json:"unit_id"`
+	// Unit is the latest known state of the unit
+	Unit *domain.Unit `json:"unit"`
+
+	PreviousLineStatus domain.LineStatus `json:"previous_line_status,omitempty"`
+	LineStatus         domain.LineStatus `json:"line_status,omitempty"`
+	Vaccine            domain.Vaccine    `json:"vaccine,omitempty"`
+}
+
+// Detector compares snapshots to find what changed
+type Detector struct {
+	// StaleAfter is how long a unit can go without updates before an EventUnitStale is emitted
+	StaleAfter time.Duration
+}
+
+// Diff returns the events that happened between previous and current, ordered by unit id.
+// Events are dated when current was fetched.
+func (d *Detector) Diff(previous, current *poller.Snapshot) []*Event {
+	before := indexByID(previous)
+	after := indexByID(current)
+	events := []*Event{}
+
+	for id, unit := range after {
+		old, existed := before[id]
+		if !existed {
+			events = append(events, &Event{Type: EventUnitAdded, UnitID: id, Unit: unit})
+			continue
+		}
+		if old.LineStatus != unit.LineStatus {
+			events = append(events, &Event{
+				Type:               EventStatusChanged,
+				UnitID:             id,
+				Unit:               unit,
+				PreviousLineStatus: old.LineStatus,
+				LineStatus:         unit.LineStatus,
+			})
+		}
+		for _, vaccine := range domain.Vaccines {
+			switch {
+			case unit.Vaccines.Has(vaccine) && !old.Vaccines.Has(vaccine):
+				events = append(events, &Event{Type: EventVaccineAvailable, UnitID: id, Unit: unit, Vaccine: vaccine})
+			case !unit.Vaccines.Has(vaccine) && old.Vaccines.Has(vaccine):
+				events = append(events, &Event{Type: EventVaccineUnavailable, UnitID: id, Unit: unit, Vaccine: vaccine})
+			}
+		}
+		if d.isStale(unit, current.FetchedAt) && !d.isStale(old, previous.FetchedAt) {
+			events = append(events, &Event{Type: EventUnitStale, UnitID: id, Unit: unit})
+		}
+	}
+	for id, unit := range before {
+		if _, exists := after[id]; !exists {
+			events = append(events, &Event{Type: EventUnitRemoved, UnitID: id, Unit: unit})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].UnitID != events[j].UnitID {
+			return events[i].UnitID < events[j].UnitID
+		}
+		return eventOrder[events[i].Type] < eventOrder[events[j].Type]
+	})
+	if current != nil {
+		for _, event := range events {
+			event.At = current.FetchedAt
+		}
+	}
+	return events
+}
+
+func (d *Detector) isStale(unit *domain.Unit, at time.Time) bool {
+	staleAfter := d.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return at.Sub(unit.LastUpdatedAt) > staleAfter
+}
+
+// eventOrder keeps the events of a unit in a stable order
+var eventOrder = map[EventType]int{
+	EventUnitAdded:          0,
+	EventStatusChanged:      1,
+	EventVaccineAvailable:   2,
+	EventVaccineUnavailable: 3,
+	EventUnitStale:          4,
+	EventUnitRemoved:        5,
+}
+
+func indexByID(snapshot *poller.Snapshot) map[int]*domain.Unit {
+	units := map[int]*domain.Unit{}
+	if snapshot == nil {
+		return units
+	}
+	for _, raw := range snapshot.Units {
+		unit, _ := domain.FromDeOlhoNaFila(raw)
+		units[unit.ID] = unit
+	}
+	return units
+}
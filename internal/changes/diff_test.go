@@ -0,0 +1,68 @@
+package changes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fetchedAt = (&prefeitura.DeOlhoNaFilaUnit{LastUpdatedAtStr: "2021-08-11 14:00:00.000"}).LastUpdatedAt()
+
+func snapshot(at time.Time, units ...*prefeitura.DeOlhoNaFilaUnit) *poller.Snapshot {
+	return &poller.Snapshot{Units: units, FetchedAt: at}
+}
+
+func TestDetector_DiffReportsAddedAndRemovedUnits(t *testing.T) {
+	d := &changes.Detector{}
+	previous := snapshot(fetchedAt, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 13:55:00.000"})
+	current := snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "2", LastUpdatedAtStr: "2021-08-11 13:55:00.000"})
+
+	events := d.Diff(previous, current)
+	require.Len(t, events, 2, "expected length to match")
+	assert.Equal(t, changes.EventUnitRemoved, events[0].Type, "expected type to match")
+	assert.Equal(t, 1, events[0].UnitID, "expected unit id to match")
+	assert.Equal(t, changes.EventUnitAdded, events[1].Type, "expected type to match")
+	assert.Equal(t, 2, events[1].UnitID, "expected unit id to match")
+	assert.Equal(t, current.FetchedAt, events[1].At, "expected events to be dated at the current snapshot")
+}
+
+func TestDetector_DiffReportsStatusAndVaccineChanges(t *testing.T) {
+	d := &changes.Detector{}
+	previous := snapshot(fetchedAt, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 13:55:00.000", LineIndexStr: "1", PfizerStr: "1", CoronaVacStr: "0"})
+	current := snapshot(fetchedAt, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 13:58:00.000", LineIndexStr: "4", PfizerStr: "0", CoronaVacStr: "1"})
+
+	events := d.Diff(previous, current)
+	require.Len(t, events, 3, "expected length to match")
+	assert.Equal(t, changes.EventStatusChanged, events[0].Type, "expected type to match")
+	assert.Equal(t, domain.LineStatusNoLine, events[0].PreviousLineStatus, "expected previous status to match")
+	assert.Equal(t, domain.LineStatusLarge, events[0].LineStatus, "expected status to match")
+	assert.Equal(t, changes.EventVaccineAvailable, events[1].Type, "expected type to match")
+	assert.Equal(t, domain.VaccineCoronaVac, events[1].Vaccine, "expected vaccine to match")
+	assert.Equal(t, changes.EventVaccineUnavailable, events[2].Type, "expected type to match")
+	assert.Equal(t, domain.VaccinePfizer, events[2].Vaccine, "expected vaccine to match")
+}
+
+func TestDetector_DiffReportsUnitStaleOnce(t *testing.T) {
+	d := &changes.Detector{StaleAfter: time.Hour}
+	unit := &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 13:30:00.000", LineIndexStr: "1"}
+
+	events := d.Diff(snapshot(fetchedAt, unit), snapshot(fetchedAt.Add(40*time.Minute), unit))
+	require.Len(t, events, 1, "expected length to match")
+	assert.Equal(t, changes.EventUnitStale, events[0].Type, "expected type to match")
+
+	events = d.Diff(snapshot(fetchedAt.Add(40*time.Minute), unit), snapshot(fetchedAt.Add(50*time.Minute), unit))
+	assert.Empty(t, events, "expected stale unit to be reported once")
+}
+
+func TestDetector_DiffIsEmptyWithoutChanges(t *testing.T) {
+	d := &changes.Detector{}
+	unit := &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 13:55:00.000", LineIndexStr: "2", PfizerStr: "1"}
+
+	assert.Empty(t, d.Diff(snapshot(fetchedAt, unit), snapshot(fetchedAt.Add(time.Minute), unit)), "expected no events")
+}
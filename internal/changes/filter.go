@@ -0,0 +1,60 @@
+package changes
+
+import (
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+// Filter selects events. Each field that is set must match (AND) and any of its values may match (OR).
+type Filter struct {
+	// RegionIDs are id_crs values
+	RegionIDs []int `json:"region_ids,omitempty"`
+	// NeighborhoodIDs are id_distrito values
+	NeighborhoodIDs []int `json:"neighborhood_ids,omitempty"`
+	// UnitIDs are id_tb_unidades values
+	UnitIDs []int `json:"unit_ids,omitempty"`
+	// Vaccines matches vaccine events about these vaccines and other events of units offering any of them
+	Vaccines []domain.Vaccine `json:"vaccines,omitempty"`
+}
+
+// Match reports whether event is selected by the filter
+func (f *Filter) Match(event *Event) bool {
+	if len(f.RegionIDs) > 0 && !containsInt(f.RegionIDs, event.Unit.RegionID) {
+		return false
+	}
+	if len(f.NeighborhoodIDs) > 0 && !containsInt(f.NeighborhoodIDs, event.Unit.NeighborhoodID) {
+		return false
+	}
+	if len(f.UnitIDs) > 0 && !containsInt(f.UnitIDs, event.UnitID) {
+		return false
+	}
+	if len(f.Vaccines) > 0 {
+		if len(event.Vaccine) > 0 {
+			return containsVaccine(f.Vaccines, event.Vaccine)
+		}
+		for _, v := range f.Vaccines {
+			if event.Unit.Vaccines.Has(v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsVaccine(values []domain.Vaccine, v domain.Vaccine) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
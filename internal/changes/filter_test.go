@@ -0,0 +1,35 @@
+package changes_test
+
+import (
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_EmptyMatchesEverything(t *testing.T) {
+	f := &changes.Filter{}
+	assert.True(t, f.Match(&changes.Event{UnitID: 1, Unit: &domain.Unit{ID: 1}}), "expected event to match")
+}
+
+func TestFilter_MatchesEveryFieldSet(t *testing.T) {
+	f := &changes.Filter{RegionIDs: []int{1, 5}, NeighborhoodIDs: []int{7}, UnitIDs: []int{10, 20}}
+	unit := &domain.Unit{ID: 10, RegionID: 5, NeighborhoodID: 7}
+
+	assert.True(t, f.Match(&changes.Event{UnitID: 10, Unit: unit}), "expected event to match")
+	assert.False(t, f.Match(&changes.Event{UnitID: 30, Unit: &domain.Unit{ID: 30, RegionID: 5, NeighborhoodID: 7}}), "expected other unit not to match")
+	assert.False(t, f.Match(&changes.Event{UnitID: 20, Unit: &domain.Unit{ID: 20, RegionID: 2, NeighborhoodID: 7}}), "expected other region not to match")
+	assert.False(t, f.Match(&changes.Event{UnitID: 20, Unit: &domain.Unit{ID: 20, RegionID: 5, NeighborhoodID: 8}}), "expected other district not to match")
+}
+
+func TestFilter_VaccinesMatchVaccineEventsAndUnitsOfferingThem(t *testing.T) {
+	f := &changes.Filter{Vaccines: []domain.Vaccine{domain.VaccinePfizer}}
+	withPfizer := &domain.Unit{ID: 1, Vaccines: domain.NewVaccineSet(domain.VaccinePfizer)}
+	withoutPfizer := &domain.Unit{ID: 2, Vaccines: domain.NewVaccineSet(domain.VaccineCoronaVac)}
+
+	assert.True(t, f.Match(&changes.Event{Type: changes.EventStatusChanged, UnitID: 1, Unit: withPfizer}), "expected unit offering pfizer to match")
+	assert.False(t, f.Match(&changes.Event{Type: changes.EventStatusChanged, UnitID: 2, Unit: withoutPfizer}), "expected unit without pfizer not to match")
+	assert.True(t, f.Match(&changes.Event{Type: changes.EventVaccineUnavailable, UnitID: 2, Unit: withoutPfizer, Vaccine: domain.VaccinePfizer}), "expected pfizer event to match")
+	assert.False(t, f.Match(&changes.Event{Type: changes.EventVaccineAvailable, UnitID: 2, Unit: withoutPfizer, Vaccine: domain.VaccineCoronaVac}), "expected coronavac event not to match")
+}
@@ -0,0 +1,140 @@
+package changes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+)
+
+const (
+	// DefaultRetention is how long events are kept in memory
+	DefaultRetention = 24 * time.Hour
+
+	// subscriptionBuffer is how many events a subscriber can fall behind before being dropped
+	subscriptionBuffer = 256
+)
+
+// Log keeps the events detected between consecutive snapshots for a while
+type Log struct {
+	detector  *Detector
+	retention time.Duration
+
+	mutex       sync.RWMutex
+	previous    *poller.Snapshot
+	lastID      int64
+	events      []*Event
+	subscribers map[chan *Event]struct{}
+}
+
+// NewLog creates a log that detects changes with detector and keeps events for retention.
+// A retention of zero uses DefaultRetention.
+func NewLog(detector *Detector, retention time.Duration) *Log {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Log{detector: detector, retention: retention, subscribers: map[chan *Event]struct{}{}}
+}
+
+// Record detects the changes since the previous snapshot recorded. The first snapshot only sets the baseline.
+// Its signature matches poller.Listener.
+func (l *Log) Record(_ context.Context, snapshot *poller.Snapshot) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	previous := l.previous
+	l.previous = snapshot
+	if previous == nil {
+		return
+	}
+
+	for _, event := range l.detector.Diff(previous, snapshot) {
+		event.ID = l.nextID(snapshot.FetchedAt)
+		l.events = append(l.events, event)
+		l.publish(event)
+	}
+	l.prune(snapshot.FetchedAt.Add(-l.retention))
+}
+
+// Since returns the events detected after since, oldest first
+func (l *Log) Since(since time.Time) []*Event {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	results := []*Event{}
+	for _, event := range l.events {
+		if event.At.After(since) {
+			results = append(results, event)
+		}
+	}
+	return results
+}
+
+// Subscribe returns the events recorded after the event with id afterID, a channel receiving every
+// event recorded from now on and a function to stop receiving them. A negative afterID skips the backlog
+// and an afterID above the latest ID, which this log never issued, replays the whole backlog.
+// The channel is closed when the subscriber falls too far behind so it can subscribe again from the
+// last event it received.
+func (l *Log) Subscribe(afterID int64) ([]*Event, <-chan *Event, func()) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if afterID > l.lastID {
+		afterID = 0
+	}
+	backlog := []*Event{}
+	if afterID >= 0 {
+		for _, event := range l.events {
+			if event.ID > afterID {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	ch := make(chan *Event, subscriptionBuffer)
+	l.subscribers[ch] = struct{}{}
+	return backlog, ch, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.unsubscribe(ch)
+	}
+}
+
+// nextID returns an ID greater than every previous one. IDs are based on the microseconds since the epoch
+// at which the snapshot was fetched so they keep increasing across restarts while staying below 2^53,
+// the largest integer JavaScript clients read exactly.
+func (l *Log) nextID(fetchedAt time.Time) int64 {
+	id := fetchedAt.UnixNano() / int64(time.Microsecond)
+	if id <= l.lastID {
+		id = l.lastID + 1
+	}
+	l.lastID = id
+	return id
+}
+
+// publish sends event to every subscriber, dropping the ones that can't keep up
+func (l *Log) publish(event *Event) {
+	for ch := range l.subscribers {
+		select {
+		case ch <- event:
+		default:
+			l.unsubscribe(ch)
+		}
+	}
+}
+
+func (l *Log) unsubscribe(ch chan *Event) {
+	if _, ok := l.subscribers[ch]; ok {
+		delete(l.subscribers, ch)
+		close(ch)
+	}
+}
+
+// prune drops events detected before the given time. Events are appended in order so only a prefix is dropped.
+func (l *Log) prune(before time.Time) {
+	keep := 0
+	for keep < len(l.events) && l.events[keep].At.Before(before) {
+		keep++
+	}
+	if keep > 0 {
+		l.events = append([]*Event(nil), l.events[keep:]...)
+	}
+}
@@ -0,0 +1,117 @@
+package changes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ poller.Listener = (&changes.Log{}).Record
+
+func TestLog_FirstSnapshotOnlySetsBaseline(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1"}))
+
+	assert.Empty(t, l.Since(time.Time{}), "expected no events")
+}
+
+func TestLog_SinceReturnsEventsAfterTimeWithIncreasingIDs(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(2*time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}, &prefeitura.DeOlhoNaFilaUnit{IDStr: "2", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+
+	events := l.Since(time.Time{})
+	require.Len(t, events, 2, "expected length to match")
+	assert.Less(t, events[0].ID, events[1].ID, "expected ids to increase")
+
+	events = l.Since(fetchedAt.Add(time.Minute))
+	require.Len(t, events, 1, "expected length to match")
+	assert.Equal(t, 2, events[0].UnitID, "expected unit id to match")
+}
+
+func TestLog_DropsEventsOlderThanRetention(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(2*time.Hour)))
+
+	events := l.Since(time.Time{})
+	require.Len(t, events, 1, "expected old event to be dropped")
+	assert.Equal(t, changes.EventUnitRemoved, events[0].Type, "expected type to match")
+}
+
+func TestLog_SubscribeReplaysBacklogAndReceivesNewEvents(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+
+	backlog, events, cancel := l.Subscribe(0)
+	defer cancel()
+	require.Len(t, backlog, 1, "expected backlog to be replayed")
+
+	l.Record(context.Background(), snapshot(fetchedAt.Add(2*time.Minute)))
+	select {
+	case event := <-events:
+		assert.Greater(t, event.ID, backlog[0].ID, "expected ids to increase")
+		assert.Equal(t, changes.EventUnitRemoved, event.Type, "expected type to match")
+	case <-time.After(time.Second):
+		t.Fatal("expected new event to be published")
+	}
+}
+
+func TestLog_IDsKeepIncreasingAcrossRestarts(t *testing.T) {
+	unit := &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}
+	before := changes.NewLog(&changes.Detector{}, time.Hour)
+	before.Record(context.Background(), snapshot(fetchedAt))
+	before.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), unit))
+	after := changes.NewLog(&changes.Detector{}, time.Hour)
+	after.Record(context.Background(), snapshot(fetchedAt.Add(2*time.Minute), unit))
+	after.Record(context.Background(), snapshot(fetchedAt.Add(3*time.Minute)))
+
+	lastBefore := before.Since(time.Time{})[0]
+	firstAfter := after.Since(time.Time{})[0]
+	assert.Greater(t, firstAfter.ID, lastBefore.ID, "expected ids of a restarted log to be greater")
+	assert.Less(t, firstAfter.ID, int64(1)<<53, "expected ids to be exact in JavaScript")
+
+	backlog, _, cancel := after.Subscribe(lastBefore.ID)
+	defer cancel()
+	assert.Equal(t, []*changes.Event{firstAfter}, backlog, "expected events after the restart to be replayed")
+}
+
+func TestLog_SubscribeAfterUnknownIDReplaysBacklog(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+	latest := l.Since(time.Time{})[0]
+
+	backlog, _, cancel := l.Subscribe(latest.ID + 1000)
+	defer cancel()
+	assert.Equal(t, []*changes.Event{latest}, backlog, "expected ids above the latest to replay the backlog")
+}
+
+func TestLog_SubscribeWithoutBacklog(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	l.Record(context.Background(), snapshot(fetchedAt))
+	l.Record(context.Background(), snapshot(fetchedAt.Add(time.Minute), &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"}))
+
+	backlog, _, cancel := l.Subscribe(-1)
+	defer cancel()
+	assert.Empty(t, backlog, "expected no backlog")
+}
+
+func TestLog_CancelClosesSubscription(t *testing.T) {
+	l := changes.NewLog(&changes.Detector{}, time.Hour)
+	_, events, cancel := l.Subscribe(-1)
+	cancel()
+	cancel()
+
+	_, open := <-events
+	assert.False(t, open, "expected channel to be closed")
+}
@@ -0,0 +1,155 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Settings configures the HTTP client used to call other services
+type Settings struct {
+	// Timeout limits a whole request, including reading the body
+	Timeout time.Duration
+	// DialTimeout limits establishing a TCP connection
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout limits the TLS handshake
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the wait for response headers once the request is sent
+	ResponseHeaderTimeout time.Duration
+	// MaxIdleConns limits idle connections kept across all hosts
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits idle connections kept for each host
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept
+	IdleConnTimeout time.Duration
+	// CABundlePath is an optional PEM file with certificates trusted in addition to the system ones
+	CABundlePath string
+}
+
+// DefaultSettings suit calls to the city hall website and to geocoding services
+var DefaultSettings = Settings{
+	Timeout:               10 * time.Second,
+	DialTimeout:           5 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 8 * time.Second,
+	MaxIdleConns:          20,
+	MaxIdleConnsPerHost:   4,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+var (
+	// ErrNoCertificates is returned when the CA bundle doesn't hold any PEM certificate
+	ErrNoCertificates = errors.New("no certificates found in CA bundle")
+	// ErrNonPublicAddress is returned by clients created with NewPublicOnly when connecting to a non public address
+	ErrNonPublicAddress = errors.New("address is not public")
+)
+
+// nonPublicNetworks are the loopback, private, link-local, shared, reserved and multicast ranges
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+// IsPublic reports whether ip is a public unicast address
+func IsPublic(ip net.IP) bool {
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// New creates a client that uses the proxy from the environment and doesn't follow redirects
+func New(settings Settings) (*http.Client, error) {
+	return newClient(settings, http.ProxyFromEnvironment, nil)
+}
+
+// NewPublicOnly creates a client like New that refuses to connect to non public addresses, for requests
+// to URLs given by users. Addresses are checked after DNS resolution so names resolving to internal
+// services are refused too. Proxies are ignored since only the proxy address could be checked.
+func NewPublicOnly(settings Settings) (*http.Client, error) {
+	return newClient(settings, nil, publicOnly)
+}
+
+func newClient(settings Settings, proxy func(*http.Request) (*url.URL, error), control func(string, string, syscall.RawConn) error) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(settings.CABundlePath) > 0 {
+		pool, err := certPool(settings.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   settings.DialTimeout,
+			KeepAlive: 30 * time.Second,
+			Control:   control,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   settings.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: settings.ResponseHeaderTimeout,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   settings.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// publicOnly is a net.Dialer Control function refusing connections to non public addresses
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// certPool returns the system certificates along with the ones in the PEM file at path
+func certPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrNoCertificates
+	}
+	return pool, nil
+}
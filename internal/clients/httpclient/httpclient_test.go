@@ -0,0 +1,98 @@
+package httpclient_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.HTTPClient = &http.Client{}
+
+func TestNew_DoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.RedirectHandler("/elsewhere", http.StatusFound))
+	defer srv.Close()
+	client, err := httpclient.New(httpclient.DefaultSettings)
+	require.NoError(t, err, "expected error to match")
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err, "expected error to match")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode, "expected redirect to be returned")
+}
+
+func TestNew_TrustsCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	untrusting, err := httpclient.New(httpclient.DefaultSettings)
+	require.NoError(t, err, "expected error to match")
+	_, err = untrusting.Get(srv.URL)
+	require.Error(t, err, "expected unknown certificate to be rejected")
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(bundle, certificate, 0600), "expected error to match")
+	settings := httpclient.DefaultSettings
+	settings.CABundlePath = bundle
+	trusting, err := httpclient.New(settings)
+	require.NoError(t, err, "expected error to match")
+
+	resp, err := trusting.Get(srv.URL)
+	require.NoError(t, err, "expected certificate in bundle to be trusted")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "expected status code to match")
+}
+
+func TestNew_RejectsBundleWithoutCertificates(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(bundle, []byte("not a certificate"), 0600), "expected error to match")
+	settings := httpclient.DefaultSettings
+	settings.CABundlePath = bundle
+
+	_, err := httpclient.New(settings)
+	assert.ErrorIs(t, err, httpclient.ErrNoCertificates, "expected error to match")
+}
+
+func TestNewPublicOnly_RefusesNonPublicAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	client, err := httpclient.NewPublicOnly(httpclient.DefaultSettings)
+	require.NoError(t, err, "expected error to match")
+
+	_, err = client.Get(srv.URL)
+	require.ErrorIs(t, err, httpclient.ErrNonPublicAddress, "expected loopback address to be refused")
+}
+
+func TestIsPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.20.0.1":      false,
+		"192.168.0.10":    false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for address, public := range cases {
+		assert.Equal(t, public, httpclient.IsPublic(net.ParseIP(address)), "expected %s to match", address)
+	}
+}
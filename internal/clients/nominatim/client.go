@@ -0,0 +1,99 @@
+package nominatim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+)
+
+const (
+	// DefaultURL is the public OpenStreetMap Nominatim search endpoint
+	DefaultURL = "https://nominatim.openstreetmap.org/search"
+	// DefaultUserAgent identifies this application as required by Nominatim's usage policy
+	DefaultUserAgent = "onde-2a-dose-backend"
+
+	citySuffix = ", São Paulo, SP, Brasil"
+)
+
+var (
+	// ErrNotFound is returned when the address could not be located
+	ErrNotFound = geo.ErrNotFound
+)
+
+// Client geocodes addresses using an OpenStreetMap Nominatim server
+type Client struct {
+	HTTPClient deps.HTTPClient
+	BaseURL    string
+	UserAgent  string
+}
+
+type searchResult struct {
+	Latitude  string `json:"lat"`
+	Longitude string `json:"lon"`
+}
+
+// Geocode returns the coordinates of the best match for the given address within São Paulo
+func (c *Client) Geocode(ctx context.Context, address string) (*geo.Coordinates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.searchURL(address), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", c.userAgent())
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("invalid response status code")
+	}
+	if resp.Body == nil {
+		return nil, errors.New("empty body")
+	}
+	defer resp.Body.Close()
+
+	results := []*searchResult{}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, err := strconv.ParseFloat(results[0].Latitude, 64)
+	if err != nil {
+		return nil, err
+	}
+	lng, err := strconv.ParseFloat(results[0].Longitude, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &geo.Coordinates{Latitude: lat, Longitude: lng}, nil
+}
+
+func (c *Client) searchURL(address string) string {
+	base := c.BaseURL
+	if len(base) == 0 {
+		base = DefaultURL
+	}
+	query := url.Values{}
+	query.Set("q", address+citySuffix)
+	query.Set("format", "json")
+	query.Set("limit", "1")
+	query.Set("countrycodes", "br")
+	return base + "?" + query.Encode()
+}
+
+func (c *Client) userAgent() string {
+	if len(c.UserAgent) == 0 {
+		return DefaultUserAgent
+	}
+	return c.UserAgent
+}
@@ -0,0 +1,74 @@
+package nominatim_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/nominatim"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.Geocoder = &nominatim.Client{}
+
+func TestClient_GeocodeErrorsWhenDownstreamErrors(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &nominatim.Client{HTTPClient: fakeClient}
+
+	fakeClient.DoReturns(nil, errors.New("error"))
+	_, err := client.Geocode(context.Background(), "Rua Vergueiro, 235")
+	require.Error(t, err, "expected error to match")
+}
+
+func TestClient_GeocodeErrorsWhenDownstreamReturnsNonOKStatusCode(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &nominatim.Client{HTTPClient: fakeClient}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+	}, nil)
+	_, err := client.Geocode(context.Background(), "Rua Vergueiro, 235")
+	require.Error(t, err, "expected error to match")
+}
+
+func TestClient_GeocodeReturnsNotFoundWhenNoResults(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &nominatim.Client{HTTPClient: fakeClient}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("[]")),
+	}, nil)
+	_, err := client.Geocode(context.Background(), "Rua Vergueiro, 235")
+	require.ErrorIs(t, err, nominatim.ErrNotFound, "expected error to match")
+}
+
+func TestClient_GeocodeReturnsFirstResult(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &nominatim.Client{HTTPClient: fakeClient, BaseURL: "http://nominatim.local/search"}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(`[{"lat":"-23.5674","lon":"-46.6397"},{"lat":"0","lon":"0"}]`)),
+	}, nil)
+	coords, err := client.Geocode(context.Background(), "Rua Vergueiro, 235")
+	require.NoError(t, err, "expected error to match")
+	assert.InDelta(t, -23.5674, coords.Latitude, 0.00001, "expected latitude to match")
+	assert.InDelta(t, -46.6397, coords.Longitude, 0.00001, "expected longitude to match")
+
+	if assert.Equal(t, 1, fakeClient.DoCallCount(), "expected a single request") {
+		req := fakeClient.DoArgsForCall(0)
+		assert.Equal(t, "nominatim.local", req.URL.Host, "expected host to match")
+		assert.Equal(t, "Rua Vergueiro, 235, São Paulo, SP, Brasil", req.URL.Query().Get("q"), "expected query to match")
+		assert.Equal(t, nominatim.DefaultUserAgent, req.Header.Get("User-Agent"), "expected user agent to match")
+	}
+}
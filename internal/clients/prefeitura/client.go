@@ -9,13 +9,16 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
 	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
 )
 
 const (
-	prefeituraURL = "https://deolhonafila.prefeitura.sp.gov.br/processadores/dados.php"
+	// DefaultBaseURL is the base URL of São Paulo's city hall De Olho Na Fila website
+	DefaultBaseURL = "https://deolhonafila.prefeitura.sp.gov.br"
+	dataPath = "/processadores/dados.php"
 	bodyKey = "dados"
 	bodyValue = "dados"
 
@@ -27,38 +30,79 @@ const (
 
 type Client struct {
 	HTTPClient deps.HTTPClient
+	// BaseURL is the scheme and host serving De Olho Na Fila data. Defaults to DefaultBaseURL when empty.
+	BaseURL string
+	// Retry configures retries of failed requests. The zero value makes a single attempt.
+	Retry RetryPolicy
 }
 
 func (c *Client) Fetch(ctx context.Context) ([]*prefeituradeps.DeOlhoNaFilaUnit, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prefeituraURL, strings.NewReader(fmt.Sprintf("%s=%s", bodyKey, bodyValue)))
-	req.Header.Add(ContentTypeHeader, FormContentType)
+	for attempt := 1; ; attempt++ {
+		results, err := c.fetchOnce(ctx)
+		if err == nil {
+			return results, nil
+		}
+		if attempt >= c.Retry.attempts() || !isRetryable(ctx, err) {
+			return nil, err
+		}
+
+		wait := c.Retry.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if c.Retry.MaxBackoff > 0 && statusErr.RetryAfter > c.Retry.MaxBackoff {
+				return nil, err
+			}
+			wait = statusErr.RetryAfter
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return nil, classifyTransportError(ctx, sleepErr)
+		}
+	}
+}
+
+func (c *Client) fetchOnce(ctx context.Context) ([]*prefeituradeps.DeOlhoNaFilaUnit, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.dataURL(), strings.NewReader(fmt.Sprintf("%s=%s", bodyKey, bodyValue)))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Add(ContentTypeHeader, FormContentType)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, classifyTransportError(ctx, err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("invalid response status code")
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
 	}
 	if resp.Body == nil {
-		return nil, errors.New("empty body")
+		return nil, &EmptyBodyError{}
 	}
 
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, classifyTransportError(ctx, err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, &EmptyBodyError{}
 	}
 
 	results := []*prefeituradeps.DeOlhoNaFilaUnit{}
 	err = json.NewDecoder(bytes.NewReader(body)).Decode(&results)
 	if err != nil {
-		fmt.Println("error decoding", string(body))
-		return nil, err
+		return nil, newDecodeError(err, body)
 	}
 
 	return results, nil
-}
\ No newline at end of file
+}
+
+func (c *Client) dataURL() string {
+	base := c.BaseURL
+	if len(base) == 0 {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + dataPath
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -42,6 +44,9 @@ func TestClient_FetchErrorsWhenDownstreamReturnsNonOKStatusCode(t *testing.T) {
 	}, nil)
 	_, err := client.Fetch(context.Background())
 	require.Error(t, err, "expected error to match")
+	var statusErr *prefeitura.StatusError
+	require.ErrorAs(t, err, &statusErr, "expected error type to match")
+	assert.Equal(t, http.StatusTeapot, statusErr.StatusCode, "expected status code to match")
 }
 
 func TestClient_FetchErrorsWhenDownstreamReturnsEmptyBody(t *testing.T) {
@@ -56,6 +61,8 @@ func TestClient_FetchErrorsWhenDownstreamReturnsEmptyBody(t *testing.T) {
 	}, nil)
 	_, err := client.Fetch(context.Background())
 	require.Error(t, err, "expected error to match")
+	var emptyErr *prefeitura.EmptyBodyError
+	require.ErrorAs(t, err, &emptyErr, "expected error type to match")
 }
 
 func TestClient_FetchErrorsWhenDownstreamReturnsNonParseableResponse(t *testing.T) {
@@ -71,6 +78,9 @@ func TestClient_FetchErrorsWhenDownstreamReturnsNonParseableResponse(t *testing.
 	}, nil)
 	_, err := client.Fetch(context.Background())
 	require.Error(t, err, "expected error to match")
+	var decodeErr *prefeitura.DecodeError
+	require.ErrorAs(t, err, &decodeErr, "expected error type to match")
+	assert.Equal(t, "{}", decodeErr.BodySample, "expected body sample to match")
 }
 
 func TestClient_FetchWorksWhenDownstreamReturnsValidEmptyResponse(t *testing.T) {
@@ -114,4 +124,213 @@ func TestClient_FetchWorksWhenDownstreamReturnsValidResponse(t *testing.T) {
 		assert.Equal(t, true, unit.HasCoronaVac(), "expected coronavac to match")
 		assert.WithinDuration(t, expectedLastUpdatedAt, unit.LastUpdatedAt(), time.Second, "expected last updated at to match")
 	}
-}
\ No newline at end of file
+}
+
+func TestClient_FetchUsesDefaultBaseURL(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+	}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("[]")),
+	}, nil)
+	_, err := client.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	require.Equal(t, 1, fakeClient.DoCallCount(), "expected a single request")
+	assert.Equal(t, "https://deolhonafila.prefeitura.sp.gov.br/processadores/dados.php", fakeClient.DoArgsForCall(0).URL.String(), "expected url to match")
+}
+
+func TestClient_FetchUsesConfiguredBaseURL(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		BaseURL:    "http://127.0.0.1:8082/",
+	}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("[]")),
+	}, nil)
+	_, err := client.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	require.Equal(t, 1, fakeClient.DoCallCount(), "expected a single request")
+	assert.Equal(t, "http://127.0.0.1:8082/processadores/dados.php", fakeClient.DoArgsForCall(0).URL.String(), "expected url to match")
+}
+
+func TestClient_FetchErrorsWithInvalidBaseURL(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		BaseURL:    "http://[::1",
+	}
+
+	_, err := client.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	assert.Equal(t, 0, fakeClient.DoCallCount(), "expected no request")
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var fastRetries = prefeitura.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Jitter:         0.5,
+}
+
+func validEmptyResponse() *http.Response {
+	return &http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("[]")),
+	}
+}
+
+func TestClient_FetchRetriesServiceUnavailable(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry:      fastRetries,
+	}
+
+	fakeClient.DoReturnsOnCall(0, &http.Response{Status: "Service Unavailable", StatusCode: http.StatusServiceUnavailable}, nil)
+	fakeClient.DoReturnsOnCall(1, &http.Response{Status: "Bad Gateway", StatusCode: http.StatusBadGateway}, nil)
+	fakeClient.DoReturnsOnCall(2, validEmptyResponse(), nil)
+	_, err := client.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Equal(t, 3, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchRetriesTimeoutsAndConnectionResets(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry:      fastRetries,
+	}
+
+	fakeClient.DoReturnsOnCall(0, nil, &url.Error{Op: "Post", URL: "http://localhost", Err: timeoutError{}})
+	fakeClient.DoReturnsOnCall(1, nil, &url.Error{Op: "Post", URL: "http://localhost", Err: syscall.ECONNRESET})
+	fakeClient.DoReturnsOnCall(2, validEmptyResponse(), nil)
+	_, err := client.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Equal(t, 3, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchGivesUpAfterMaxAttempts(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry:      fastRetries,
+	}
+
+	fakeClient.DoReturns(&http.Response{Status: "Gateway Timeout", StatusCode: http.StatusGatewayTimeout}, nil)
+	_, err := client.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	assert.Equal(t, 3, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchDoesNotRetryNonRetryableFailures(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry:      fastRetries,
+	}
+
+	fakeClient.DoReturnsOnCall(0, &http.Response{Status: "Internal Server Error", StatusCode: http.StatusInternalServerError}, nil)
+	fakeClient.DoReturnsOnCall(1, nil, errors.New("unsupported protocol scheme"))
+	_, err := client.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	_, err = client.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	assert.Equal(t, 2, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchGivesUpWhenRetryAfterExceedsMaxBackoff(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry:      fastRetries,
+	}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": []string{"120"}},
+	}, nil)
+	_, err := client.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	assert.Equal(t, 1, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchStopsRetryingWhenContextIsDone(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+		Retry: prefeitura.RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Hour,
+		},
+	}
+
+	fakeClient.DoReturns(&http.Response{Status: "Service Unavailable", StatusCode: http.StatusServiceUnavailable}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Fetch(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "expected error to match")
+	var timeoutErr *prefeitura.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr, "expected error type to match")
+	assert.Equal(t, 1, fakeClient.DoCallCount(), "expected call count to match")
+}
+
+func TestClient_FetchTruncatesBodySampleOfDecodeErrors(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+	}
+
+	fakeClient.DoReturns(&http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("<html>" + strings.Repeat("a", 1000))),
+	}, nil)
+	_, err := client.Fetch(context.Background())
+	var decodeErr *prefeitura.DecodeError
+	require.ErrorAs(t, err, &decodeErr, "expected error type to match")
+	assert.Len(t, decodeErr.BodySample, 256, "expected body sample size to match")
+	assert.True(t, strings.HasPrefix(decodeErr.BodySample, "<html>"), "expected body sample to match")
+}
+
+func TestClient_FetchReturnsTimeoutErrorWhenDownstreamTimesOut(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+	}
+
+	fakeClient.DoReturns(nil, &url.Error{Op: "Post", URL: "http://localhost", Err: timeoutError{}})
+	_, err := client.Fetch(context.Background())
+	var timeoutErr *prefeitura.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr, "expected error type to match")
+}
+
+func TestClient_FetchReturnsCanceledErrorWhenCallerCancels(t *testing.T) {
+	fakeClient := &dependenciesfakes.FakeHTTPClient{}
+	client := &prefeitura.Client{
+		HTTPClient: fakeClient,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fakeClient.DoReturns(nil, &url.Error{Op: "Post", URL: "http://localhost", Err: context.Canceled})
+	_, err := client.Fetch(ctx)
+	var canceledErr *prefeitura.CanceledError
+	require.ErrorAs(t, err, &canceledErr, "expected error type to match")
+	assert.ErrorIs(t, err, context.Canceled, "expected wrapped error to match")
+}
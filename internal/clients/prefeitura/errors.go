@@ -0,0 +1,97 @@
+package prefeitura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// maxBodySampleSize is the maximum number of bytes of an undecodable body kept in a DecodeError
+	maxBodySampleSize = 256
+)
+
+// StatusError is returned when the server responds with a status code other than 200
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the server through the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("invalid response status code: %d", e.StatusCode)
+}
+
+// EmptyBodyError is returned when the server responds without a body
+type EmptyBodyError struct{}
+
+func (e *EmptyBodyError) Error() string {
+	return "empty body"
+}
+
+// DecodeError is returned when the body of the response can't be decoded
+type DecodeError struct {
+	Err error
+	// BodySample holds the beginning of the body that couldn't be decoded
+	BodySample string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding response: %s", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when the server takes too long to respond
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout fetching data: %s", e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// CanceledError is returned when the caller cancels the request before it completes
+type CanceledError struct {
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("canceled fetching data: %s", e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+func newDecodeError(err error, body []byte) *DecodeError {
+	sample := body
+	if len(sample) > maxBodySampleSize {
+		sample = sample[:maxBodySampleSize]
+	}
+	return &DecodeError{Err: err, BodySample: string(sample)}
+}
+
+// classifyTransportError wraps errors from sending the request or reading the response into
+// TimeoutError or CanceledError when applicable
+func classifyTransportError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &CanceledError{Err: err}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &TimeoutError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/cache"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+)
+
+const (
+	// FileEnv is the environment variable with the path of the config file. The -config flag takes precedence
+	FileEnv = "CONFIG_FILE"
+
+	LogLevelInfo  = "info"
+	LogLevelError = "error"
+)
+
+var logLevels = map[string]int{LogLevelInfo: 0, LogLevelError: 1}
+
+// Config holds the settings of the server
+type Config struct {
+	Port        string
+	UpstreamURL string
+	LogLevel    string
+	CORSOrigins []string
+
+	PollInterval     time.Duration
+	GeocodeInterval  time.Duration
+	ReadinessMaxAge  time.Duration
+	HistoryRetention time.Duration
+	// MaxStale is how long after the poll interval the latest data is served while refreshes fail. The
+	// poller owns the staleness of the data served, Cache.MaxStale only applies when the cache is on.
+	MaxStale time.Duration
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	HTTPClient httpclient.Settings
+	Retry      prefeitura.RetryPolicy
+	Breaker    breaker.Settings
+	Cache      cache.Settings
+
+	GeocodesPath string
+	HistoryPath  string
+	WebhooksPath string
+
+	// WebhookAdminToken manages every webhook subscription. The API refuses every request when no token is set.
+	WebhookAdminToken string
+	// WebhookPartnerTokens are partner:token pairs. Each partner only manages its own subscriptions.
+	WebhookPartnerTokens []string
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	return &Config{
+		Port:        "8080",
+		UpstreamURL: prefeitura.DefaultBaseURL,
+		LogLevel:    LogLevelInfo,
+
+		PollInterval: time.Minute,
+		// Nominatim's usage policy allows 1 request per second
+		GeocodeInterval: time.Second,
+		ReadinessMaxAge: 10 * time.Minute,
+		// Keep a month of history
+		HistoryRetention: 30 * 24 * time.Hour,
+		MaxStale:         5 * time.Minute,
+
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: time.Minute,
+		IdleTimeout:  2 * time.Minute,
+		// In-flight requests have this long to finish once a signal is received
+		ShutdownTimeout: 20 * time.Second,
+
+		HTTPClient: httpclient.DefaultSettings,
+		Retry:      prefeitura.DefaultRetryPolicy,
+		Breaker:    breaker.DefaultSettings,
+		// The poller already refreshes the data, so caching is off unless a TTL is set
+		Cache: cache.Settings{MaxStale: cache.DefaultSettings.MaxStale, RefreshTimeout: cache.DefaultSettings.RefreshTimeout},
+
+		GeocodesPath: "geocodes.json",
+		HistoryPath:  "history.db",
+		WebhooksPath: "webhooks.json",
+	}
+}
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	// Problems maps the setting, environment variable or flag to the reason it was rejected
+	Problems map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Problems))
+	for name := range e.Problems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, name+": "+e.Problems[name])
+	}
+	return "invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// Load merges, from lowest to highest precedence, the defaults, the YAML or TOML file given by
+// -config or FileEnv, the environment variables and the flags in args. It registers its flags in fs.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	path, _ := lookupEnv(FileEnv)
+	fs.StringVar(&path, "config", path, "YAML or TOML file with settings, overridden by environment variables and flags (env "+FileEnv+")")
+	type flagValue struct {
+		setting *setting
+		value   string
+	}
+	var fromFlags []flagValue
+	for _, s := range settings {
+		s := s
+		fs.Func(s.flag, s.usage+" (env "+s.env+")", func(v string) error {
+			fromFlags = append(fromFlags, flagValue{setting: s, value: v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	problems := map[string]string{}
+	if len(path) > 0 {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string]*setting, len(settings))
+		for _, s := range settings {
+			byKey[s.key] = s
+		}
+		for key, raw := range values {
+			s, ok := byKey[key]
+			if !ok {
+				problems[key] = "unknown setting in " + path
+				continue
+			}
+			s.set(problems, key, fileValue(raw))
+		}
+	}
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok && len(v) > 0 {
+			s.set(problems, s.env, v)
+		}
+	}
+	for _, f := range fromFlags {
+		f.setting.set(problems, "-"+f.setting.flag, f.value)
+	}
+	if len(problems) == 0 {
+		for _, s := range settings {
+			if s.check == nil {
+				continue
+			}
+			if problem := s.check(); len(problem) > 0 {
+				problems[s.key] = problem
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// WebhookPartners maps each partner token to the partner name
+func (c *Config) WebhookPartners() map[string]string {
+	partners := make(map[string]string, len(c.WebhookPartnerTokens))
+	for _, pair := range c.WebhookPartnerTokens {
+		partner, token := splitPartnerToken(pair)
+		partners[token] = partner
+	}
+	return partners
+}
+
+// Logs reports whether messages of level should be logged
+func (c *Config) Logs(level string) bool {
+	return logLevels[level] >= logLevels[c.LogLevel]
+}
+
+// Print writes the settings to w in the config file format, with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range c.settings() {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.key})
+		if list, ok := s.value.(*listValue); ok {
+			seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, item := range *list.v {
+				if s.redact != nil {
+					item = s.redact(item)
+				}
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+			doc.Content = append(doc.Content, seq)
+			continue
+		}
+		v := s.value.String()
+		if s.redact != nil {
+			v = s.redact(v)
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+	}
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// readFile returns the settings in the YAML or TOML file at path, chosen by its extension
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+	default:
+		return nil, fmt.Errorf("config file %s must have a .yaml, .yml or .toml extension", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %v", path, err)
+	}
+	return values, nil
+}
+
+// fileValue formats a value decoded from a config file the way it would be set in the environment
+func fileValue(raw interface{}) string {
+	if raw == nil {
+		return ""
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return fmt.Sprint(raw)
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, fmt.Sprint(item))
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,361 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// anyOrigin allows browsers on every origin to call the API
+const anyOrigin = "*"
+
+// setting binds a field of Config to its config file key, environment variable and flag
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+	// check returns why the value is invalid or an empty string
+	check func() string
+	// redact hides secrets in the value when the config is printed
+	redact func(string) string
+}
+
+// set parses v into the setting, recording why it was rejected under source
+func (s *setting) set(problems map[string]string, source, v string) {
+	if err := s.value.Set(v); err != nil {
+		problems[source] = err.Error()
+	}
+}
+
+func (c *Config) settings() []*setting {
+	return []*setting{
+		{key: "port", env: "PORT", flag: "port", usage: "port to listen on",
+			value: (*stringValue)(&c.Port), check: port(&c.Port)},
+		{key: "upstream_url", env: "DEOLHONAFILA_ADDR", flag: "deolhonafila-addr", usage: "address (host:port or URL) of the De Olho Na Fila server",
+			value: (*addrValue)(&c.UpstreamURL), check: httpURL(&c.UpstreamURL), redact: redactPassword},
+		{key: "log_level", env: "LOG_LEVEL", flag: "log-level", usage: "info or error",
+			value: (*stringValue)(&c.LogLevel), check: logLevel(&c.LogLevel)},
+		{key: "cors_origins", env: "CORS_ORIGINS", flag: "cors-origins", usage: "comma separated origins allowed to call the API from browsers, or *",
+			value: &listValue{v: &c.CORSOrigins}, check: origins(&c.CORSOrigins)},
+
+		{key: "poll_interval", env: "POLL_INTERVAL", flag: "poll-interval", usage: "interval between refreshes of the data, which also limits each refresh",
+			value: (*durationValue)(&c.PollInterval), check: positive(&c.PollInterval)},
+		{key: "geocode_interval", env: "GEOCODE_INTERVAL", flag: "geocode-interval", usage: "minimum interval between requests to the geocoder",
+			value: (*durationValue)(&c.GeocodeInterval), check: positive(&c.GeocodeInterval)},
+		{key: "readiness_max_age", env: "READINESS_MAX_AGE", flag: "readiness-max-age", usage: "age of the data after which readiness is degraded",
+			value: (*durationValue)(&c.ReadinessMaxAge), check: positive(&c.ReadinessMaxAge)},
+		{key: "max_stale", env: "MAX_STALE", flag: "max-stale", usage: "how long after the poll interval data is still served while refreshes fail",
+			value: (*durationValue)(&c.MaxStale), check: positive(&c.MaxStale)},
+		{key: "history_retention", env: "HISTORY_RETENTION", flag: "history-retention", usage: "how long unit history is kept, 0 to keep everything",
+			value: (*durationValue)(&c.HistoryRetention), check: notNegative(&c.HistoryRetention)},
+
+		{key: "read_timeout", env: "READ_TIMEOUT", flag: "read-timeout", usage: "limit to read a request",
+			value: (*durationValue)(&c.ReadTimeout), check: positive(&c.ReadTimeout)},
+		{key: "write_timeout", env: "WRITE_TIMEOUT", flag: "write-timeout", usage: "limit to write a response, except for event streams which limit each write",
+			value: (*durationValue)(&c.WriteTimeout), check: positive(&c.WriteTimeout)},
+		{key: "idle_timeout", env: "IDLE_TIMEOUT", flag: "idle-timeout", usage: "how long idle keep-alive connections are kept",
+			value: (*durationValue)(&c.IdleTimeout), check: positive(&c.IdleTimeout)},
+		{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "how long in-flight requests have to finish on shutdown",
+			value: (*durationValue)(&c.ShutdownTimeout), check: positive(&c.ShutdownTimeout)},
+
+		{key: "http_timeout", env: "HTTP_TIMEOUT", flag: "http-timeout", usage: "limit of requests to other services",
+			value: (*durationValue)(&c.HTTPClient.Timeout), check: positive(&c.HTTPClient.Timeout)},
+		{key: "http_dial_timeout", env: "HTTP_DIAL_TIMEOUT", flag: "http-dial-timeout", usage: "limit to connect to other services",
+			value: (*durationValue)(&c.HTTPClient.DialTimeout), check: positive(&c.HTTPClient.DialTimeout)},
+		{key: "http_tls_handshake_timeout", env: "HTTP_TLS_HANDSHAKE_TIMEOUT", flag: "http-tls-handshake-timeout", usage: "limit of TLS handshakes with other services",
+			value: (*durationValue)(&c.HTTPClient.TLSHandshakeTimeout), check: positive(&c.HTTPClient.TLSHandshakeTimeout)},
+		{key: "http_response_header_timeout", env: "HTTP_RESPONSE_HEADER_TIMEOUT", flag: "http-response-header-timeout", usage: "limit to receive response headers from other services",
+			value: (*durationValue)(&c.HTTPClient.ResponseHeaderTimeout), check: positive(&c.HTTPClient.ResponseHeaderTimeout)},
+		{key: "http_max_idle_conns", env: "HTTP_MAX_IDLE_CONNS", flag: "http-max-idle-conns", usage: "idle connections kept across all hosts",
+			value: (*intValue)(&c.HTTPClient.MaxIdleConns), check: atLeastOne(&c.HTTPClient.MaxIdleConns)},
+		{key: "http_max_idle_conns_per_host", env: "HTTP_MAX_IDLE_CONNS_PER_HOST", flag: "http-max-idle-conns-per-host", usage: "idle connections kept for each host",
+			value: (*intValue)(&c.HTTPClient.MaxIdleConnsPerHost), check: atLeastOne(&c.HTTPClient.MaxIdleConnsPerHost)},
+		{key: "http_idle_conn_timeout", env: "HTTP_IDLE_CONN_TIMEOUT", flag: "http-idle-conn-timeout", usage: "how long idle connections to other services are kept",
+			value: (*durationValue)(&c.HTTPClient.IdleConnTimeout), check: positive(&c.HTTPClient.IdleConnTimeout)},
+		{key: "http_ca_bundle", env: "HTTP_CA_BUNDLE", flag: "http-ca-bundle", usage: "PEM file with certificates trusted in addition to the system ones",
+			value: (*stringValue)(&c.HTTPClient.CABundlePath), check: optionalFile(&c.HTTPClient.CABundlePath)},
+
+		{key: "retry_max_attempts", env: "RETRY_MAX_ATTEMPTS", flag: "retry-max-attempts", usage: "requests made to the upstream on each fetch, including retries",
+			value: (*intValue)(&c.Retry.MaxAttempts), check: atLeastOne(&c.Retry.MaxAttempts)},
+		{key: "retry_initial_backoff", env: "RETRY_INITIAL_BACKOFF", flag: "retry-initial-backoff", usage: "wait before the first retry, doubled on each following one",
+			value: (*durationValue)(&c.Retry.InitialBackoff), check: notNegative(&c.Retry.InitialBackoff)},
+		{key: "retry_max_backoff", env: "RETRY_MAX_BACKOFF", flag: "retry-max-backoff", usage: "longest wait between retries, 0 for no limit",
+			value: (*durationValue)(&c.Retry.MaxBackoff), check: notNegative(&c.Retry.MaxBackoff)},
+		{key: "retry_jitter", env: "RETRY_JITTER", flag: "retry-jitter", usage: "fraction of each wait between retries randomly removed",
+			value: (*floatValue)(&c.Retry.Jitter), check: fraction(&c.Retry.Jitter)},
+
+		{key: "breaker_failure_threshold", env: "BREAKER_FAILURE_THRESHOLD", flag: "breaker-failure-threshold", usage: "consecutive upstream failures that open the circuit",
+			value: (*intValue)(&c.Breaker.FailureThreshold), check: atLeastOne(&c.Breaker.FailureThreshold)},
+		{key: "breaker_reset_timeout", env: "BREAKER_RESET_TIMEOUT", flag: "breaker-reset-timeout", usage: "how long the circuit stays open",
+			value: (*durationValue)(&c.Breaker.ResetTimeout), check: positive(&c.Breaker.ResetTimeout)},
+
+		{key: "cache_ttl", env: "CACHE_TTL", flag: "cache-ttl", usage: "how long fetched data is reused, 0 to disable",
+			value: (*durationValue)(&c.Cache.TTL), check: notNegative(&c.Cache.TTL)},
+		{key: "cache_max_stale", env: "CACHE_MAX_STALE", flag: "cache-max-stale", usage: "how long after the TTL cached data is still served while the upstream fails, when the cache is on",
+			value: (*durationValue)(&c.Cache.MaxStale), check: notNegative(&c.Cache.MaxStale)},
+		{key: "cache_refresh_timeout", env: "CACHE_REFRESH_TIMEOUT", flag: "cache-refresh-timeout", usage: "limit of each refresh of the cached data",
+			value: (*durationValue)(&c.Cache.RefreshTimeout), check: positive(&c.Cache.RefreshTimeout)},
+
+		{key: "geocodes_path", env: "GEOCODES_PATH", flag: "geocodes-path", usage: "file storing the coordinates of units",
+			value: (*stringValue)(&c.GeocodesPath), check: required(&c.GeocodesPath)},
+		{key: "history_path", env: "HISTORY_PATH", flag: "history-path", usage: "database storing the history of units",
+			value: (*stringValue)(&c.HistoryPath), check: required(&c.HistoryPath)},
+		{key: "webhooks_path", env: "WEBHOOKS_PATH", flag: "webhooks-path", usage: "file storing webhook subscriptions",
+			value: (*stringValue)(&c.WebhooksPath), check: required(&c.WebhooksPath)},
+		{key: "webhook_admin_token", env: "WEBHOOK_ADMIN_TOKEN", flag: "webhook-admin-token", usage: "bearer token managing every webhook subscription",
+			value: (*stringValue)(&c.WebhookAdminToken), check: token(&c.WebhookAdminToken), redact: redactSecret},
+		{key: "webhook_partner_tokens", env: "WEBHOOK_PARTNER_TOKENS", flag: "webhook-partner-tokens", usage: "comma separated partner:token pairs, each partner managing its own webhook subscriptions",
+			value: &listValue{v: &c.WebhookPartnerTokens}, check: partnerTokens(&c.WebhookPartnerTokens, &c.WebhookAdminToken), redact: redactPartnerToken},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+// addrValue accepts either a full URL or a host:port address and keeps a URL
+type addrValue string
+
+func (v *addrValue) String() string { return string(*v) }
+
+func (v *addrValue) Set(s string) error {
+	if len(s) > 0 && !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	*v = addrValue(s)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return errors.New("must be an integer")
+	}
+	*v = intValue(i)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return errors.New("must be a duration such as 30s or 1h")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return errors.New("must be a number")
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+// listValue reads comma separated values, replacing the previous ones
+type listValue struct {
+	v *[]string
+}
+
+func (l *listValue) String() string {
+	if l.v == nil {
+		return ""
+	}
+	return strings.Join(*l.v, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	*l.v = items
+	return nil
+}
+
+func positive(d *time.Duration) func() string {
+	return func() string {
+		if *d <= 0 {
+			return "must be positive"
+		}
+		return ""
+	}
+}
+
+func notNegative(d *time.Duration) func() string {
+	return func() string {
+		if *d < 0 {
+			return "must not be negative"
+		}
+		return ""
+	}
+}
+
+func atLeastOne(i *int) func() string {
+	return func() string {
+		if *i < 1 {
+			return "must be at least 1"
+		}
+		return ""
+	}
+}
+
+func fraction(f *float64) func() string {
+	return func() string {
+		if *f < 0 || *f > 1 {
+			return "must be between 0 and 1"
+		}
+		return ""
+	}
+}
+
+func required(s *string) func() string {
+	return func() string {
+		if len(*s) == 0 {
+			return "must not be empty"
+		}
+		return ""
+	}
+}
+
+func port(s *string) func() string {
+	return func() string {
+		p, err := strconv.Atoi(*s)
+		if err != nil || p < 1 || p > 65535 {
+			return "must be a port number between 1 and 65535"
+		}
+		return ""
+	}
+}
+
+func httpURL(s *string) func() string {
+	return func() string {
+		u, err := url.Parse(*s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return "must be an http or https URL"
+		}
+		return ""
+	}
+}
+
+func logLevel(s *string) func() string {
+	return func() string {
+		if _, ok := logLevels[*s]; !ok {
+			return "must be info or error"
+		}
+		return ""
+	}
+}
+
+func origins(list *[]string) func() string {
+	return func() string {
+		for _, origin := range *list {
+			if origin == anyOrigin {
+				continue
+			}
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(u.Path) > 0 || u.User != nil {
+				return "must be * or origins such as https://example.org"
+			}
+		}
+		return ""
+	}
+}
+
+func optionalFile(path *string) func() string {
+	return func() string {
+		if len(*path) == 0 {
+			return ""
+		}
+		if _, err := os.Stat(*path); err != nil {
+			return "must be a readable file"
+		}
+		return ""
+	}
+}
+
+// minTokenLength keeps tokens from being guessed
+const minTokenLength = 16
+
+func token(s *string) func() string {
+	return func() string {
+		if len(*s) > 0 && len(*s) < minTokenLength {
+			return fmt.Sprintf("must have at least %d characters", minTokenLength)
+		}
+		return ""
+	}
+}
+
+func partnerTokens(list *[]string, adminToken *string) func() string {
+	return func() string {
+		seen := map[string]bool{*adminToken: true}
+		for _, pair := range *list {
+			partner, token := splitPartnerToken(pair)
+			if len(partner) == 0 || len(token) < minTokenLength {
+				return fmt.Sprintf("must be partner:token pairs with tokens of at least %d characters", minTokenLength)
+			}
+			if seen[token] {
+				return "must not repeat tokens"
+			}
+			seen[token] = true
+		}
+		return ""
+	}
+}
+
+// splitPartnerToken splits a partner:token pair
+func splitPartnerToken(pair string) (string, string) {
+	i := strings.Index(pair, ":")
+	if i < 0 {
+		return "", ""
+	}
+	return pair[:i], pair[i+1:]
+}
+
+func redactSecret(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return "xxxxx"
+}
+
+func redactPartnerToken(pair string) string {
+	partner, _ := splitPartnerToken(pair)
+	return partner + ":xxxxx"
+}
+
+// redactPassword hides the password of URLs with credentials
+func redactPassword(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
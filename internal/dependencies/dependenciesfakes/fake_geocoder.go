@@ -0,0 +1,118 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package dependenciesfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+)
+
+type FakeGeocoder struct {
+	GeocodeStub        func(context.Context, string) (*geo.Coordinates, error)
+	geocodeMutex       sync.RWMutex
+	geocodeArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	geocodeReturns struct {
+		result1 *geo.Coordinates
+		result2 error
+	}
+	geocodeReturnsOnCall map[int]struct {
+		result1 *geo.Coordinates
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeGeocoder) Geocode(arg1 context.Context, arg2 string) (*geo.Coordinates, error) {
+	fake.geocodeMutex.Lock()
+	ret, specificReturn := fake.geocodeReturnsOnCall[len(fake.geocodeArgsForCall)]
+	fake.geocodeArgsForCall = append(fake.geocodeArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GeocodeStub
+	fakeReturns := fake.geocodeReturns
+	fake.recordInvocation("Geocode", []interface{}{arg1, arg2})
+	fake.geocodeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGeocoder) GeocodeCallCount() int {
+	fake.geocodeMutex.RLock()
+	defer fake.geocodeMutex.RUnlock()
+	return len(fake.geocodeArgsForCall)
+}
+
+func (fake *FakeGeocoder) GeocodeCalls(stub func(context.Context, string) (*geo.Coordinates, error)) {
+	fake.geocodeMutex.Lock()
+	defer fake.geocodeMutex.Unlock()
+	fake.GeocodeStub = stub
+}
+
+func (fake *FakeGeocoder) GeocodeArgsForCall(i int) (context.Context, string) {
+	fake.geocodeMutex.RLock()
+	defer fake.geocodeMutex.RUnlock()
+	argsForCall := fake.geocodeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeGeocoder) GeocodeReturns(result1 *geo.Coordinates, result2 error) {
+	fake.geocodeMutex.Lock()
+	defer fake.geocodeMutex.Unlock()
+	fake.GeocodeStub = nil
+	fake.geocodeReturns = struct {
+		result1 *geo.Coordinates
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGeocoder) GeocodeReturnsOnCall(i int, result1 *geo.Coordinates, result2 error) {
+	fake.geocodeMutex.Lock()
+	defer fake.geocodeMutex.Unlock()
+	fake.GeocodeStub = nil
+	if fake.geocodeReturnsOnCall == nil {
+		fake.geocodeReturnsOnCall = make(map[int]struct {
+			result1 *geo.Coordinates
+			result2 error
+		})
+	}
+	fake.geocodeReturnsOnCall[i] = struct {
+		result1 *geo.Coordinates
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGeocoder) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeGeocoder) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ dependencies.Geocoder = new(FakeGeocoder)
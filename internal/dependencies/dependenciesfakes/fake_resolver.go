@@ -0,0 +1,118 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package dependenciesfakes
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+)
+
+type FakeResolver struct {
+	LookupIPAddrStub        func(context.Context, string) ([]net.IPAddr, error)
+	lookupIPAddrMutex       sync.RWMutex
+	lookupIPAddrArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	lookupIPAddrReturns struct {
+		result1 []net.IPAddr
+		result2 error
+	}
+	lookupIPAddrReturnsOnCall map[int]struct {
+		result1 []net.IPAddr
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeResolver) LookupIPAddr(arg1 context.Context, arg2 string) ([]net.IPAddr, error) {
+	fake.lookupIPAddrMutex.Lock()
+	ret, specificReturn := fake.lookupIPAddrReturnsOnCall[len(fake.lookupIPAddrArgsForCall)]
+	fake.lookupIPAddrArgsForCall = append(fake.lookupIPAddrArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.LookupIPAddrStub
+	fakeReturns := fake.lookupIPAddrReturns
+	fake.recordInvocation("LookupIPAddr", []interface{}{arg1, arg2})
+	fake.lookupIPAddrMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeResolver) LookupIPAddrCallCount() int {
+	fake.lookupIPAddrMutex.RLock()
+	defer fake.lookupIPAddrMutex.RUnlock()
+	return len(fake.lookupIPAddrArgsForCall)
+}
+
+func (fake *FakeResolver) LookupIPAddrCalls(stub func(context.Context, string) ([]net.IPAddr, error)) {
+	fake.lookupIPAddrMutex.Lock()
+	defer fake.lookupIPAddrMutex.Unlock()
+	fake.LookupIPAddrStub = stub
+}
+
+func (fake *FakeResolver) LookupIPAddrArgsForCall(i int) (context.Context, string) {
+	fake.lookupIPAddrMutex.RLock()
+	defer fake.lookupIPAddrMutex.RUnlock()
+	argsForCall := fake.lookupIPAddrArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeResolver) LookupIPAddrReturns(result1 []net.IPAddr, result2 error) {
+	fake.lookupIPAddrMutex.Lock()
+	defer fake.lookupIPAddrMutex.Unlock()
+	fake.LookupIPAddrStub = nil
+	fake.lookupIPAddrReturns = struct {
+		result1 []net.IPAddr
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeResolver) LookupIPAddrReturnsOnCall(i int, result1 []net.IPAddr, result2 error) {
+	fake.lookupIPAddrMutex.Lock()
+	defer fake.lookupIPAddrMutex.Unlock()
+	fake.LookupIPAddrStub = nil
+	if fake.lookupIPAddrReturnsOnCall == nil {
+		fake.lookupIPAddrReturnsOnCall = make(map[int]struct {
+			result1 []net.IPAddr
+			result2 error
+		})
+	}
+	fake.lookupIPAddrReturnsOnCall[i] = struct {
+		result1 []net.IPAddr
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeResolver) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeResolver) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ dependencies.Resolver = new(FakeResolver)
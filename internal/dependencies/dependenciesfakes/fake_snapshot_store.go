@@ -0,0 +1,278 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package dependenciesfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+type FakeSnapshotStore struct {
+	HistoryStub        func(int, time.Time, time.Time) ([]*prefeitura.DeOlhoNaFilaUnit, error)
+	historyMutex       sync.RWMutex
+	historyArgsForCall []struct {
+		arg1 int
+		arg2 time.Time
+		arg3 time.Time
+	}
+	historyReturns struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}
+	historyReturnsOnCall map[int]struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}
+	SaveStub        func(time.Time, []*prefeitura.DeOlhoNaFilaUnit) ([]*prefeitura.DeOlhoNaFilaUnit, error)
+	saveMutex       sync.RWMutex
+	saveArgsForCall []struct {
+		arg1 time.Time
+		arg2 []*prefeitura.DeOlhoNaFilaUnit
+	}
+	saveReturns struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}
+	saveReturnsOnCall map[int]struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}
+	WalkStub        func(time.Time, func(*prefeitura.DeOlhoNaFilaUnit) error) error
+	walkMutex       sync.RWMutex
+	walkArgsForCall []struct {
+		arg1 time.Time
+		arg2 func(*prefeitura.DeOlhoNaFilaUnit) error
+	}
+	walkReturns struct {
+		result1 error
+	}
+	walkReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeSnapshotStore) History(arg1 int, arg2 time.Time, arg3 time.Time) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	fake.historyMutex.Lock()
+	ret, specificReturn := fake.historyReturnsOnCall[len(fake.historyArgsForCall)]
+	fake.historyArgsForCall = append(fake.historyArgsForCall, struct {
+		arg1 int
+		arg2 time.Time
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	stub := fake.HistoryStub
+	fakeReturns := fake.historyReturns
+	fake.recordInvocation("History", []interface{}{arg1, arg2, arg3})
+	fake.historyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSnapshotStore) HistoryCallCount() int {
+	fake.historyMutex.RLock()
+	defer fake.historyMutex.RUnlock()
+	return len(fake.historyArgsForCall)
+}
+
+func (fake *FakeSnapshotStore) HistoryCalls(stub func(int, time.Time, time.Time) ([]*prefeitura.DeOlhoNaFilaUnit, error)) {
+	fake.historyMutex.Lock()
+	defer fake.historyMutex.Unlock()
+	fake.HistoryStub = stub
+}
+
+func (fake *FakeSnapshotStore) HistoryArgsForCall(i int) (int, time.Time, time.Time) {
+	fake.historyMutex.RLock()
+	defer fake.historyMutex.RUnlock()
+	argsForCall := fake.historyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSnapshotStore) HistoryReturns(result1 []*prefeitura.DeOlhoNaFilaUnit, result2 error) {
+	fake.historyMutex.Lock()
+	defer fake.historyMutex.Unlock()
+	fake.HistoryStub = nil
+	fake.historyReturns = struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSnapshotStore) HistoryReturnsOnCall(i int, result1 []*prefeitura.DeOlhoNaFilaUnit, result2 error) {
+	fake.historyMutex.Lock()
+	defer fake.historyMutex.Unlock()
+	fake.HistoryStub = nil
+	if fake.historyReturnsOnCall == nil {
+		fake.historyReturnsOnCall = make(map[int]struct {
+			result1 []*prefeitura.DeOlhoNaFilaUnit
+			result2 error
+		})
+	}
+	fake.historyReturnsOnCall[i] = struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSnapshotStore) Save(arg1 time.Time, arg2 []*prefeitura.DeOlhoNaFilaUnit) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	var arg2Copy []*prefeitura.DeOlhoNaFilaUnit
+	if arg2 != nil {
+		arg2Copy = make([]*prefeitura.DeOlhoNaFilaUnit, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.saveMutex.Lock()
+	ret, specificReturn := fake.saveReturnsOnCall[len(fake.saveArgsForCall)]
+	fake.saveArgsForCall = append(fake.saveArgsForCall, struct {
+		arg1 time.Time
+		arg2 []*prefeitura.DeOlhoNaFilaUnit
+	}{arg1, arg2Copy})
+	stub := fake.SaveStub
+	fakeReturns := fake.saveReturns
+	fake.recordInvocation("Save", []interface{}{arg1, arg2Copy})
+	fake.saveMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSnapshotStore) SaveCallCount() int {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return len(fake.saveArgsForCall)
+}
+
+func (fake *FakeSnapshotStore) SaveCalls(stub func(time.Time, []*prefeitura.DeOlhoNaFilaUnit) ([]*prefeitura.DeOlhoNaFilaUnit, error)) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = stub
+}
+
+func (fake *FakeSnapshotStore) SaveArgsForCall(i int) (time.Time, []*prefeitura.DeOlhoNaFilaUnit) {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	argsForCall := fake.saveArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSnapshotStore) SaveReturns(result1 []*prefeitura.DeOlhoNaFilaUnit, result2 error) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = nil
+	fake.saveReturns = struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSnapshotStore) SaveReturnsOnCall(i int, result1 []*prefeitura.DeOlhoNaFilaUnit, result2 error) {
+	fake.saveMutex.Lock()
+	defer fake.saveMutex.Unlock()
+	fake.SaveStub = nil
+	if fake.saveReturnsOnCall == nil {
+		fake.saveReturnsOnCall = make(map[int]struct {
+			result1 []*prefeitura.DeOlhoNaFilaUnit
+			result2 error
+		})
+	}
+	fake.saveReturnsOnCall[i] = struct {
+		result1 []*prefeitura.DeOlhoNaFilaUnit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSnapshotStore) Walk(arg1 time.Time, arg2 func(*prefeitura.DeOlhoNaFilaUnit) error) error {
+	fake.walkMutex.Lock()
+	ret, specificReturn := fake.walkReturnsOnCall[len(fake.walkArgsForCall)]
+	fake.walkArgsForCall = append(fake.walkArgsForCall, struct {
+		arg1 time.Time
+		arg2 func(*prefeitura.DeOlhoNaFilaUnit) error
+	}{arg1, arg2})
+	stub := fake.WalkStub
+	fakeReturns := fake.walkReturns
+	fake.recordInvocation("Walk", []interface{}{arg1, arg2})
+	fake.walkMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeSnapshotStore) WalkCallCount() int {
+	fake.walkMutex.RLock()
+	defer fake.walkMutex.RUnlock()
+	return len(fake.walkArgsForCall)
+}
+
+func (fake *FakeSnapshotStore) WalkCalls(stub func(time.Time, func(*prefeitura.DeOlhoNaFilaUnit) error) error) {
+	fake.walkMutex.Lock()
+	defer fake.walkMutex.Unlock()
+	fake.WalkStub = stub
+}
+
+func (fake *FakeSnapshotStore) WalkArgsForCall(i int) (time.Time, func(*prefeitura.DeOlhoNaFilaUnit) error) {
+	fake.walkMutex.RLock()
+	defer fake.walkMutex.RUnlock()
+	argsForCall := fake.walkArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeSnapshotStore) WalkReturns(result1 error) {
+	fake.walkMutex.Lock()
+	defer fake.walkMutex.Unlock()
+	fake.WalkStub = nil
+	fake.walkReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeSnapshotStore) WalkReturnsOnCall(i int, result1 error) {
+	fake.walkMutex.Lock()
+	defer fake.walkMutex.Unlock()
+	fake.WalkStub = nil
+	if fake.walkReturnsOnCall == nil {
+		fake.walkReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.walkReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeSnapshotStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeSnapshotStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ dependencies.SnapshotStore = new(FakeSnapshotStore)
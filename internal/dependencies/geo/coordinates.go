@@ -0,0 +1,34 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrNotFound is returned by geocoders when an address could not be located
+var ErrNotFound = errors.New("address not found")
+
+// Coordinates represents a point on the globe using decimal degrees
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+const (
+	earthRadiusKm = 6371.0
+)
+
+// DistanceKm returns the great-circle distance between two points using the haversine formula
+func (c *Coordinates) DistanceKm(other *Coordinates) float64 {
+	lat1 := toRadians(c.Latitude)
+	lat2 := toRadians(other.Latitude)
+	dLat := lat2 - lat1
+	dLng := toRadians(other.Longitude - c.Longitude)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,23 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoordinates_DistanceKmIsZeroForSamePoint(t *testing.T) {
+	p := &geo.Coordinates{Latitude: -23.5505, Longitude: -46.6333}
+	assert.InDelta(t, 0, p.DistanceKm(p), 0.000001, "expected distance to match")
+}
+
+func TestCoordinates_DistanceKmBetweenKnownPoints(t *testing.T) {
+	se := &geo.Coordinates{Latitude: -23.5503, Longitude: -46.6339}
+	ibirapuera := &geo.Coordinates{Latitude: -23.5874, Longitude: -46.6576}
+	rio := &geo.Coordinates{Latitude: -22.9068, Longitude: -43.1729}
+
+	assert.InDelta(t, 4.79, se.DistanceKm(ibirapuera), 0.05, "expected distance to match")
+	assert.InDelta(t, ibirapuera.DistanceKm(se), se.DistanceKm(ibirapuera), 0.000001, "expected distance to be symmetric")
+	assert.InDelta(t, 361, se.DistanceKm(rio), 2, "expected distance to match")
+}
@@ -0,0 +1,14 @@
+package dependencies
+
+import (
+	"context"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+)
+
+//counterfeiter:generate . Geocoder
+
+// Geocoder is an interface to translate addresses into coordinates
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (*geo.Coordinates, error)
+}
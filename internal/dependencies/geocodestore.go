@@ -0,0 +1,19 @@
+package dependencies
+
+import (
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+)
+
+//counterfeiter:generate . GeocodeStore
+
+// GeocodeStore remembers the coordinates of each unit along with the address used to find them
+type GeocodeStore interface {
+	// Lookup reports whether the unit was geocoded with the given address and returns its coordinates,
+	// which are nil when the address could not be located
+	Lookup(unitID int, address string) (*geo.Coordinates, bool)
+	// Save stores the coordinates found for the unit using the given address, or nil when it wasn't found.
+	// Saved entries might only be persisted on the next Flush.
+	Save(unitID int, address string, coords *geo.Coordinates) error
+	// Flush persists the entries saved since the last call
+	Flush() error
+}
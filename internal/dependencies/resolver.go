@@ -0,0 +1,13 @@
+package dependencies
+
+import (
+	"context"
+	"net"
+)
+
+//counterfeiter:generate . Resolver
+
+// Resolver looks up the addresses of hosts, as net.Resolver does
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
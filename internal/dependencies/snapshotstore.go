@@ -0,0 +1,21 @@
+package dependencies
+
+import (
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+//counterfeiter:generate . SnapshotStore
+
+// SnapshotStore persists the history of units fetched from De Olho Na Fila
+type SnapshotStore interface {
+	// Save records units fetched at fetchedAt. Units whose data_hora was already recorded are ignored.
+	// It returns the units that were recorded.
+	Save(fetchedAt time.Time, units []*prefeitura.DeOlhoNaFilaUnit) ([]*prefeitura.DeOlhoNaFilaUnit, error)
+	// History returns the recorded versions of a unit last updated between from and to, oldest first
+	History(unitID int, from, to time.Time) ([]*prefeitura.DeOlhoNaFilaUnit, error)
+	// Walk calls fn with every recorded version last updated since from, oldest first for each unit.
+	// It stops at the first error returned by fn.
+	Walk(from time.Time, fn func(*prefeitura.DeOlhoNaFilaUnit) error) error
+}
@@ -0,0 +1,17 @@
+package dependencies
+
+import (
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+)
+
+//counterfeiter:generate . SubscriptionStore
+
+// SubscriptionStore persists webhook subscriptions
+type SubscriptionStore interface {
+	// List returns every subscription ordered by creation
+	List() ([]*webhook.Subscription, error)
+	// Save creates or replaces the subscription with the same ID
+	Save(subscription *webhook.Subscription) error
+	// Delete removes a subscription and reports whether it existed
+	Delete(id string) (bool, error)
+}
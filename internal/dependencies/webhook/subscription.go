@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+// Subscription asks for changes in units matching its filters to be posted to URL.
+// Filters set must all match and any of their values may match.
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Owner is the partner that created the subscription or empty when it was created with the admin token
+	Owner string `json:"owner,omitempty"`
+	// Secret signs deliveries so receivers can check they came from this server
+	Secret string `json:"secret,omitempty"`
+
+	RegionIDs       []int            `json:"region_ids,omitempty"`
+	NeighborhoodIDs []int            `json:"neighborhood_ids,omitempty"`
+	UnitIDs         []int            `json:"unit_ids,omitempty"`
+	Vaccines        []domain.Vaccine `json:"vaccines,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// PostType is the kind of vaccination post
+type PostType string
+
+// Known post types
+const (
+	PostTypeUnknown   PostType = "unknown"
+	PostTypeFixed     PostType = "fixed"
+	PostTypeDriveThru PostType = "drive_thru"
+	PostTypeMegaPost  PostType = "mega_post"
+	PostTypeMobile    PostType = "mobile"
+)
+
+// postTypesByID maps id_tipo_posto to post types
+var postTypesByID = map[int]PostType{
+	1: PostTypeFixed,
+	2: PostTypeDriveThru,
+	3: PostTypeMegaPost,
+	4: PostTypeMobile,
+}
+
+// LineStatus is the size of the line at a vaccination post
+type LineStatus string
+
+// Known line statuses
+const (
+	LineStatusUnknown        LineStatus = "unknown"
+	LineStatusNoLine         LineStatus = "no_line"
+	LineStatusSmall          LineStatus = "small"
+	LineStatusMedium         LineStatus = "medium"
+	LineStatusLarge          LineStatus = "large"
+	LineStatusClosed         LineStatus = "closed"
+	LineStatusAwaitingSupply LineStatus = "awaiting_supply"
+)
+
+// lineStatusesByIndex maps indice_fila to line statuses
+var lineStatusesByIndex = map[int]LineStatus{
+	1: LineStatusNoLine,
+	2: LineStatusSmall,
+	3: LineStatusMedium,
+	4: LineStatusLarge,
+	5: LineStatusClosed,
+	6: LineStatusAwaitingSupply,
+}
+
+// LineStatusFromIndex returns the line status for an indice_fila value or LineStatusUnknown
+func LineStatusFromIndex(index int) LineStatus {
+	if status, ok := lineStatusesByIndex[index]; ok {
+		return status
+	}
+	return LineStatusUnknown
+}
+
+// ParseLineStatus returns the known line status with the given value
+func ParseLineStatus(v string) (LineStatus, bool) {
+	for _, status := range lineStatusesByIndex {
+		if string(status) == v {
+			return status, true
+		}
+	}
+	return LineStatusUnknown, false
+}
+
+// Region is the health coordination region (CRS) of the city a post belongs to
+type Region string
+
+// Known regions
+const (
+	RegionUnknown    Region = "unknown"
+	RegionCenter     Region = "center"
+	RegionEast       Region = "east"
+	RegionNorth      Region = "north"
+	RegionWest       Region = "west"
+	RegionSouth      Region = "south"
+	RegionMegaDrives Region = "mega_drives"
+)
+
+// regionsByID maps id_crs to regions
+var regionsByID = map[int]Region{
+	1: RegionCenter,
+	2: RegionEast,
+	3: RegionNorth,
+	4: RegionWest,
+	5: RegionSouth,
+	6: RegionMegaDrives,
+}
+
+// Vaccine is a COVID-19 vaccine brand
+type Vaccine string
+
+// Known vaccines
+const (
+	VaccineCoronaVac   Vaccine = "coronavac"
+	VaccineAstraZeneca Vaccine = "astrazeneca"
+	VaccinePfizer      Vaccine = "pfizer"
+)
+
+// Vaccines lists every known vaccine in a stable order
+var Vaccines = []Vaccine{VaccineCoronaVac, VaccineAstraZeneca, VaccinePfizer}
+
+// VaccineSet is a set of vaccines
+type VaccineSet map[Vaccine]struct{}
+
+// NewVaccineSet creates a set with the given vaccines
+func NewVaccineSet(vaccines ...Vaccine) VaccineSet {
+	s := VaccineSet{}
+	for _, v := range vaccines {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// Has returns whether the vaccine is in the set
+func (s VaccineSet) Has(v Vaccine) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Slice returns the vaccines in the set sorted by name
+func (s VaccineSet) Slice() []Vaccine {
+	vaccines := make([]Vaccine, 0, len(s))
+	for v := range s {
+		vaccines = append(vaccines, v)
+	}
+	sort.Slice(vaccines, func(i, j int) bool { return vaccines[i] < vaccines[j] })
+	return vaccines
+}
+
+// MarshalJSON encodes the set as a sorted array
+func (s VaccineSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Slice())
+}
+
+// UnmarshalJSON decodes the set from an array
+func (s *VaccineSet) UnmarshalJSON(data []byte) error {
+	vaccines := []Vaccine{}
+	if err := json.Unmarshal(data, &vaccines); err != nil {
+		return err
+	}
+	*s = NewVaccineSet(vaccines...)
+	return nil
+}
@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+// Unit is a vaccination post with typed values derived from prefeitura.DeOlhoNaFilaUnit
+type Unit struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	Address        string     `json:"address"`
+	PostType       PostType   `json:"post_type"`
+	NeighborhoodID int        `json:"neighborhood_id"`
+	Neighborhood   string     `json:"neighborhood"`
+	RegionID       int        `json:"region_id"`
+	Region         Region     `json:"region"`
+	LastUpdatedAt  time.Time  `json:"last_updated_at"`
+	LineIndex      int        `json:"line_index"`
+	LineStatus     LineStatus `json:"line_status"`
+	Vaccines       VaccineSet `json:"vaccines"`
+
+	*geo.Coordinates
+}
+
+// ValidationError lists every problem found converting a unit
+type ValidationError struct {
+	UnitID   string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid unit %q: %s", e.UnitID, strings.Join(e.Problems, "; "))
+}
+
+// FromDeOlhoNaFila converts a unit from the city hall payload into a Unit.
+// Every field that can't be parsed is reported in a *ValidationError. The returned unit is never nil
+// and holds zero or unknown values for those fields so callers can decide whether to use it.
+func FromDeOlhoNaFila(u *prefeitura.DeOlhoNaFilaUnit) (*Unit, error) {
+	p := &parser{}
+	unit := &Unit{
+		ID:             p.int("id_tb_unidades", u.IDStr),
+		Name:           strings.TrimSpace(u.Name),
+		Address:        strings.TrimSpace(u.Address),
+		NeighborhoodID: p.int("id_distrito", u.NeighborhoodIDStr),
+		Neighborhood:   strings.TrimSpace(u.NeighborhoodName),
+		RegionID:       p.int("id_crs", u.RegionIDStr),
+		LastUpdatedAt:  p.time("data_hora", u.LastUpdatedAtStr),
+		LineIndex:      p.int("indice_fila", u.LineIndexStr),
+		Vaccines:       VaccineSet{},
+	}
+
+	unit.PostType = PostTypeUnknown
+	if typeID := p.int("id_tipo_posto", u.TypeIDStr); typeID != 0 {
+		if postType, ok := postTypesByID[typeID]; ok {
+			unit.PostType = postType
+		} else {
+			p.problem("id_tipo_posto", "unknown post type %d (%q)", typeID, u.TypeName)
+		}
+	}
+	unit.Region = RegionUnknown
+	if region, ok := regionsByID[unit.RegionID]; ok {
+		unit.Region = region
+	} else if unit.RegionID != 0 {
+		p.problem("id_crs", "unknown region %d (%q)", unit.RegionID, u.RegionName)
+	}
+	unit.LineStatus = LineStatusUnknown
+	if status, ok := lineStatusesByIndex[unit.LineIndex]; ok {
+		unit.LineStatus = status
+	} else if unit.LineIndex != 0 {
+		p.problem("indice_fila", "unknown line index %d (%q)", unit.LineIndex, u.LineStatus)
+	}
+
+	if p.bool("coronavac", u.CoronaVacStr) {
+		unit.Vaccines[VaccineCoronaVac] = struct{}{}
+	}
+	if p.bool("astrazeneca", u.AstraZenecaStr) {
+		unit.Vaccines[VaccineAstraZeneca] = struct{}{}
+	}
+	if p.bool("pfizer", u.PfizerStr) {
+		unit.Vaccines[VaccinePfizer] = struct{}{}
+	}
+
+	if len(p.problems) > 0 {
+		return unit, &ValidationError{UnitID: u.IDStr, Problems: p.problems}
+	}
+	return unit, nil
+}
+
+// parser accumulates problems found while parsing fields of a unit
+type parser struct {
+	problems []string
+}
+
+func (p *parser) problem(field string, format string, args ...interface{}) {
+	p.problems = append(p.problems, fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) int(field string, v string) int {
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		p.problem(field, "invalid integer %q", v)
+		return 0
+	}
+	return i
+}
+
+func (p *parser) bool(field string, v string) bool {
+	switch strings.TrimSpace(v) {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	p.problem(field, "invalid flag %q", v)
+	return false
+}
+
+func (p *parser) time(field string, v string) time.Time {
+	t, err := time.Parse(prefeitura.DateLayout, fmt.Sprintf("%s-03:00", strings.TrimSpace(v)))
+	if err != nil {
+		p.problem(field, "invalid date %q", v)
+		return time.Time{}
+	}
+	return t
+}
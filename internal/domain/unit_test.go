@@ -0,0 +1,86 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validUnit() *prefeitura.DeOlhoNaFilaUnit {
+	return &prefeitura.DeOlhoNaFilaUnit{
+		IDStr:             "1",
+		Name:              "UBS HUMAITÁ - DR. JOÃO DE AZEVEDO LAGE",
+		Address:           "R. HUMAITÁ, 520 - BELA VISTA",
+		TypeName:          "POSTO FIXO",
+		TypeIDStr:         "1",
+		NeighborhoodName:  "Bela Vista",
+		NeighborhoodIDStr: "1",
+		RegionName:        "CENTRO",
+		RegionIDStr:       "1",
+		LastUpdatedAtStr:  "2021-08-11 11:50:27.413",
+		LineIndexStr:      "3",
+		LineStatus:        "FILA MÉDIA",
+		CoronaVacStr:      "1",
+		AstraZenecaStr:    "0",
+		PfizerStr:         "1",
+	}
+}
+
+func TestFromDeOlhoNaFila_ConvertsValidUnit(t *testing.T) {
+	unit, err := domain.FromDeOlhoNaFila(validUnit())
+	require.NoError(t, err, "expected error to match")
+
+	expectedLastUpdatedAt, err := time.Parse(time.RFC3339, "2021-08-11T11:50:27.413-03:00")
+	require.NoError(t, err, "expected error to match")
+	assert.Equal(t, 1, unit.ID, "expected id to match")
+	assert.Equal(t, "UBS HUMAITÁ - DR. JOÃO DE AZEVEDO LAGE", unit.Name, "expected name to match")
+	assert.Equal(t, domain.PostTypeFixed, unit.PostType, "expected post type to match")
+	assert.Equal(t, domain.RegionCenter, unit.Region, "expected region to match")
+	assert.Equal(t, 1, unit.NeighborhoodID, "expected neighborhood id to match")
+	assert.Equal(t, domain.LineStatusMedium, unit.LineStatus, "expected line status to match")
+	assert.Equal(t, 3, unit.LineIndex, "expected line index to match")
+	assert.True(t, expectedLastUpdatedAt.Equal(unit.LastUpdatedAt), "expected last updated at to match")
+	assert.Equal(t, []domain.Vaccine{domain.VaccineCoronaVac, domain.VaccinePfizer}, unit.Vaccines.Slice(), "expected vaccines to match")
+	assert.False(t, unit.Vaccines.Has(domain.VaccineAstraZeneca), "expected astrazeneca to be unavailable")
+}
+
+func TestFromDeOlhoNaFila_ReportsEveryProblem(t *testing.T) {
+	raw := validUnit()
+	raw.LastUpdatedAtStr = "ontem"
+	raw.PfizerStr = "false"
+	raw.RegionIDStr = "42"
+	raw.LineIndexStr = "x"
+
+	unit, err := domain.FromDeOlhoNaFila(raw)
+	var validationErr *domain.ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected validation error, got %v", err)
+	assert.Equal(t, "1", validationErr.UnitID, "expected unit id to match")
+	assert.Len(t, validationErr.Problems, 4, "expected problems to match: %v", validationErr.Problems)
+
+	require.NotNil(t, unit, "expected partial unit")
+	assert.Equal(t, "UBS HUMAITÁ - DR. JOÃO DE AZEVEDO LAGE", unit.Name, "expected valid fields to be kept")
+	assert.True(t, unit.LastUpdatedAt.IsZero(), "expected invalid date to be zero")
+	assert.Equal(t, domain.RegionUnknown, unit.Region, "expected region to be unknown")
+	assert.Equal(t, domain.LineStatusUnknown, unit.LineStatus, "expected line status to be unknown")
+}
+
+func TestUnit_MarshalsTypedValues(t *testing.T) {
+	unit, err := domain.FromDeOlhoNaFila(validUnit())
+	require.NoError(t, err, "expected error to match")
+
+	body, err := json.Marshal(unit)
+	require.NoError(t, err, "expected error to match")
+	decoded := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(body, &decoded), "expected error to match")
+	assert.Equal(t, float64(1), decoded["id"], "expected id to match")
+	assert.Equal(t, "medium", decoded["line_status"], "expected line status to match")
+	assert.Equal(t, "2021-08-11T11:50:27.413-03:00", decoded["last_updated_at"], "expected last updated at to match")
+	assert.Equal(t, []interface{}{"coronavac", "pfizer"}, decoded["vaccines"], "expected vaccines to match")
+	assert.NotContains(t, decoded, "latitude", "expected no coordinates when unknown")
+}
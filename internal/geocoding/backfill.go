@@ -0,0 +1,128 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+)
+
+// flushEvery bounds how many geocoded units are lost if the process stops before the queue drains
+const flushEvery = 50
+
+// Backfill geocodes, in the background, the units that aren't in the store yet. Requests to the
+// geocoder are spaced by at least the interval. Addresses that can't be located are stored too,
+// so they are only looked up again when they change.
+type Backfill struct {
+	geocoder deps.Geocoder
+	store    deps.GeocodeStore
+	interval time.Duration
+	ll       *log.Logger
+
+	mutex   sync.Mutex
+	pending []*prefeitura.DeOlhoNaFilaUnit
+	wake    chan struct{}
+}
+
+// NewBackfill creates a backfill saving the coordinates found by geocoder into store
+func NewBackfill(geocoder deps.Geocoder, store deps.GeocodeStore, interval time.Duration, ll *log.Logger) *Backfill {
+	return &Backfill{geocoder: geocoder, store: store, interval: interval, ll: ll, wake: make(chan struct{}, 1)}
+}
+
+// Record queues the units of snapshot missing from the store, replacing the previous queue. It is a poller.Listener.
+func (b *Backfill) Record(_ context.Context, snapshot *poller.Snapshot) {
+	missing := []*prefeitura.DeOlhoNaFilaUnit{}
+	for _, unit := range snapshot.Units {
+		if _, found := b.store.Lookup(unit.ID(), unit.Address); !found {
+			missing = append(missing, unit)
+		}
+	}
+
+	b.mutex.Lock()
+	b.pending = missing
+	b.mutex.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run geocodes the queued units until ctx is done, flushing the store when the queue drains
+func (b *Backfill) Run(ctx context.Context) {
+	defer b.flush()
+
+	saved := 0
+	for {
+		unit := b.next()
+		if unit == nil {
+			if saved > 0 {
+				b.flush()
+				saved = 0
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.wake:
+				continue
+			}
+		}
+
+		if b.geocode(ctx, unit) {
+			saved++
+		}
+		if saved >= flushEvery {
+			b.flush()
+			saved = 0
+		}
+
+		wait := time.NewTimer(b.interval)
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return
+		case <-wait.C:
+		}
+	}
+}
+
+func (b *Backfill) next() *prefeitura.DeOlhoNaFilaUnit {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.pending) == 0 {
+		return nil
+	}
+	unit := b.pending[0]
+	b.pending = b.pending[1:]
+	return unit
+}
+
+// geocode locates unit and reports whether the result was saved. Other failures are retried on the next snapshot.
+func (b *Backfill) geocode(ctx context.Context, unit *prefeitura.DeOlhoNaFilaUnit) bool {
+	coords, err := b.geocoder.Geocode(ctx, unit.Address)
+	if errors.Is(err, geo.ErrNotFound) {
+		coords, err = nil, nil
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			b.ll.Printf("error geocoding unit %d: %v", unit.ID(), err)
+		}
+		return false
+	}
+	if err := b.store.Save(unit.ID(), unit.Address, coords); err != nil {
+		b.ll.Printf("error saving coordinates of unit %d: %v", unit.ID(), err)
+		return false
+	}
+	return true
+}
+
+func (b *Backfill) flush() {
+	if err := b.store.Flush(); err != nil {
+		b.ll.Println("error persisting coordinates:", err)
+	}
+}
@@ -0,0 +1,96 @@
+package geocoding_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/geocoding"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = log.New(ioutil.Discard, "", 0)
+
+func TestBackfill_GeocodesMissingUnitsAndRemembersUnknownAddresses(t *testing.T) {
+	geocoder := &dependenciesfakes.FakeGeocoder{}
+	geocoder.GeocodeStub = func(_ context.Context, address string) (*geo.Coordinates, error) {
+		switch address {
+		case "Rua nova, 1":
+			return &geo.Coordinates{Latitude: -23.7, Longitude: -46.7}, nil
+		case "Lugar nenhum":
+			return nil, geo.ErrNotFound
+		default:
+			return nil, errors.New("boom")
+		}
+	}
+	store := &dependenciesfakes.FakeGeocodeStore{}
+	store.LookupStub = func(id int, _ string) (*geo.Coordinates, bool) {
+		return nil, id == 1
+	}
+	backfill := geocoding.NewBackfill(geocoder, store, time.Millisecond, discardLogger)
+
+	backfill.Record(context.Background(), &poller.Snapshot{Units: []*prefeitura.DeOlhoNaFilaUnit{
+		{IDStr: "1", Address: "Rua conhecida, 0"},
+		{IDStr: "2", Address: "Rua nova, 1"},
+		{IDStr: "3", Address: "Lugar nenhum"},
+		{IDStr: "4", Address: "Rua instável, 2"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		backfill.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return store.FlushCallCount() > 0 }, time.Second, time.Millisecond, "expected store to be flushed once the queue drains")
+	cancel()
+	<-done
+
+	assert.Equal(t, 3, geocoder.GeocodeCallCount(), "expected only units missing from the store to be geocoded")
+	require.Equal(t, 2, store.SaveCallCount(), "expected transient failures not to be saved")
+	id, address, coords := store.SaveArgsForCall(0)
+	assert.Equal(t, 2, id, "expected id to match")
+	assert.Equal(t, "Rua nova, 1", address, "expected address to match")
+	assert.Equal(t, &geo.Coordinates{Latitude: -23.7, Longitude: -46.7}, coords, "expected coordinates to match")
+	id, _, coords = store.SaveArgsForCall(1)
+	assert.Equal(t, 3, id, "expected unknown address to be saved")
+	assert.Nil(t, coords, "expected no coordinates for unknown address")
+}
+
+func TestBackfill_SpacesRequestsByInterval(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		calls []time.Time
+	)
+	geocoder := &dependenciesfakes.FakeGeocoder{}
+	geocoder.GeocodeStub = func(context.Context, string) (*geo.Coordinates, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, time.Now())
+		return &geo.Coordinates{}, nil
+	}
+	interval := 20 * time.Millisecond
+	backfill := geocoding.NewBackfill(geocoder, &dependenciesfakes.FakeGeocodeStore{}, interval, discardLogger)
+	backfill.Record(context.Background(), &poller.Snapshot{Units: []*prefeitura.DeOlhoNaFilaUnit{
+		{IDStr: "1"}, {IDStr: "2"}, {IDStr: "3"},
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go backfill.Run(ctx)
+	require.Eventually(t, func() bool { return geocoder.GeocodeCallCount() == 3 }, time.Second, time.Millisecond, "expected every unit to be geocoded")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i := 1; i < len(calls); i++ {
+		assert.GreaterOrEqual(t, int64(calls[i].Sub(calls[i-1])), int64(interval), "expected requests to be spaced by the interval")
+	}
+}
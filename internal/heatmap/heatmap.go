@@ -0,0 +1,151 @@
+package heatmap
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	// The server runs on images without a timezone database
+	_ "time/tzdata"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+const (
+	// Timezone is where weekdays and hours are computed since it's where the units are
+	Timezone = "America/Sao_Paulo"
+
+	// maxOpenLineIndex is the largest indice_fila of a working unit. Closed units and units
+	// awaiting supply say nothing about the wait so they are left out of the aggregation.
+	maxOpenLineIndex = 4
+)
+
+// Cell aggregates the line index of a unit over every update made in an hour of a weekday
+type Cell struct {
+	Weekday          string  `json:"weekday"`
+	Hour             int     `json:"hour"`
+	Samples          int     `json:"samples"`
+	AverageLineIndex float64 `json:"average_line_index"`
+	MedianLineIndex  int     `json:"p50_line_index"`
+	P90LineIndex     int     `json:"p90_line_index"`
+}
+
+// histogram counts how many updates had each line index
+type histogram [maxOpenLineIndex + 1]int
+
+// unitHeatmap holds the histograms of a unit by weekday and hour
+type unitHeatmap struct {
+	lastUpdatedAt time.Time
+	cells         [7][24]histogram
+}
+
+// Heatmap aggregates the line index of every unit by weekday and hour.
+// Units are added as they're fetched and versions already added are ignored so
+// the same snapshot can be added several times.
+type Heatmap struct {
+	location *time.Location
+
+	mutex sync.RWMutex
+	units map[int]*unitHeatmap
+}
+
+// New creates an empty heatmap
+func New() (*Heatmap, error) {
+	location, err := time.LoadLocation(Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &Heatmap{location: location, units: map[int]*unitHeatmap{}}, nil
+}
+
+// Add aggregates the units that were updated since they were last added
+func (h *Heatmap) Add(units ...*prefeitura.DeOlhoNaFilaUnit) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, unit := range units {
+		updatedAt := unit.LastUpdatedAt()
+		if !updatedAt.After(time.Unix(0, 0)) {
+			// data_hora couldn't be parsed
+			continue
+		}
+		entry, ok := h.units[unit.ID()]
+		if !ok {
+			entry = &unitHeatmap{}
+			h.units[unit.ID()] = entry
+		}
+		if !updatedAt.After(entry.lastUpdatedAt) {
+			continue
+		}
+		entry.lastUpdatedAt = updatedAt
+
+		lineIndex := unit.LineIndex()
+		if lineIndex < 1 || lineIndex > maxOpenLineIndex {
+			continue
+		}
+		local := updatedAt.In(h.location)
+		entry.cells[local.Weekday()][local.Hour()][lineIndex]++
+	}
+}
+
+// Unit returns the cells with samples for a unit, ordered by weekday and hour.
+// It returns false when the unit was never added.
+func (h *Heatmap) Unit(id int) ([]*Cell, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	entry, ok := h.units[id]
+	if !ok {
+		return nil, false
+	}
+	return entry.toCells(), true
+}
+
+// UnitIDs returns the id of every unit added, sorted
+func (h *Heatmap) UnitIDs() []int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	ids := make([]int, 0, len(h.units))
+	for id := range h.units {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (u *unitHeatmap) toCells() []*Cell {
+	cells := []*Cell{}
+	for weekday, hours := range u.cells {
+		for hour, counts := range hours {
+			samples, sum := 0, 0
+			for lineIndex, count := range counts {
+				samples += count
+				sum += lineIndex * count
+			}
+			if samples == 0 {
+				continue
+			}
+			cells = append(cells, &Cell{
+				Weekday:          strings.ToLower(time.Weekday(weekday).String()),
+				Hour:             hour,
+				Samples:          samples,
+				AverageLineIndex: float64(sum) / float64(samples),
+				MedianLineIndex:  counts.percentile(50, samples),
+				P90LineIndex:     counts.percentile(90, samples),
+			})
+		}
+	}
+	return cells
+}
+
+// percentile returns the nearest-rank percentile p of the histogram
+func (c *histogram) percentile(p float64, samples int) int {
+	rank := int(math.Ceil(p / 100 * float64(samples)))
+	seen := 0
+	for lineIndex, count := range c {
+		seen += count
+		if seen >= rank && count > 0 {
+			return lineIndex
+		}
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package heatmap_test
+
+import (
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/heatmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHeatmap(t *testing.T) *heatmap.Heatmap {
+	h, err := heatmap.New()
+	require.NoError(t, err, "expected error to match")
+	return h
+}
+
+func unit(updatedAt, lineIndex string) *prefeitura.DeOlhoNaFilaUnit {
+	return &prefeitura.DeOlhoNaFilaUnit{IDStr: "1", LastUpdatedAtStr: updatedAt, LineIndexStr: lineIndex}
+}
+
+func TestHeatmap_AggregatesByWeekdayAndHourInSaoPaulo(t *testing.T) {
+	h := newHeatmap(t)
+	// 2021-08-11 was a Wednesday
+	h.Add(
+		unit("2021-08-11 14:05:00.000", "1"),
+		unit("2021-08-11 14:20:00.000", "1"),
+		unit("2021-08-11 14:40:00.000", "2"),
+		unit("2021-08-11 14:55:00.000", "4"),
+		unit("2021-08-12 09:00:00.000", "3"),
+	)
+
+	cells, found := h.Unit(1)
+	require.True(t, found, "expected unit to be found")
+	require.Len(t, cells, 2, "expected one cell per weekday and hour")
+	assert.Equal(t, &heatmap.Cell{
+		Weekday:          "wednesday",
+		Hour:             14,
+		Samples:          4,
+		AverageLineIndex: 2,
+		MedianLineIndex:  1,
+		P90LineIndex:     4,
+	}, cells[0], "expected cell to match")
+	assert.Equal(t, "thursday", cells[1].Weekday, "expected weekday to match")
+	assert.Equal(t, 9, cells[1].Hour, "expected hour to match")
+}
+
+func TestHeatmap_IgnoresVersionsAlreadyAdded(t *testing.T) {
+	h := newHeatmap(t)
+	h.Add(unit("2021-08-11 14:05:00.000", "1"))
+	h.Add(unit("2021-08-11 14:05:00.000", "1"), unit("2021-08-11 14:20:00.000", "3"))
+	h.Add(unit("2021-08-11 14:20:00.000", "3"))
+
+	cells, found := h.Unit(1)
+	require.True(t, found, "expected unit to be found")
+	require.Len(t, cells, 1, "expected length to match")
+	assert.Equal(t, 2, cells[0].Samples, "expected each version to be counted once")
+}
+
+func TestHeatmap_IgnoresClosedUnits(t *testing.T) {
+	h := newHeatmap(t)
+	h.Add(unit("2021-08-11 14:05:00.000", "5"), unit("2021-08-11 15:05:00.000", "6"))
+
+	cells, found := h.Unit(1)
+	require.True(t, found, "expected unit to be found")
+	assert.Empty(t, cells, "expected no cells")
+}
+
+func TestHeatmap_UnitIsNotFoundWhenNeverAdded(t *testing.T) {
+	h := newHeatmap(t)
+
+	_, found := h.Unit(1)
+	assert.False(t, found, "expected unit not to be found")
+	assert.Empty(t, h.UnitIDs(), "expected no units")
+}
@@ -0,0 +1,26 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect returns the metrics in r in the Prometheus text format
+func collect(t *testing.T, r prometheus.Gatherer) string {
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(r, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, "expected status code to match")
+	return recorder.Body.String()
+}
+
+func TestNewRegistry_IncludesRuntimeMetrics(t *testing.T) {
+	output := collect(t, metrics.NewRegistry())
+	assert.Contains(t, output, "\ngo_goroutines ", "expected Go runtime metrics")
+}
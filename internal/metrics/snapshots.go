@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var snapshotAgeDesc = prometheus.NewDesc("onde2adose_snapshot_age_seconds", "Seconds since the latest successful fetch from De Olho Na Fila.", nil, nil)
+
+// Snapshots exposes metrics derived from the latest snapshot
+type Snapshots struct {
+	units *prometheus.GaugeVec
+
+	mutex     sync.RWMutex
+	fetchedAt time.Time
+}
+
+// NewSnapshots registers the snapshot metrics in r
+func NewSnapshots(r prometheus.Registerer) *Snapshots {
+	s := &Snapshots{
+		units: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "onde2adose_units",
+			Help: "Units in the latest snapshot by line status (status_fila) and region (crs).",
+		}, []string{"line_status", "region"}),
+	}
+	r.MustRegister(s)
+	return s
+}
+
+// Record updates the metrics with snapshot. Its signature matches poller.Listener.
+func (s *Snapshots) Record(_ context.Context, snapshot *poller.Snapshot) {
+	counts := map[[2]string]int{}
+	for _, raw := range snapshot.Units {
+		unit, _ := domain.FromDeOlhoNaFila(raw)
+		counts[[2]string{string(unit.LineStatus), string(unit.Region)}]++
+	}
+	s.units.Reset()
+	for labels, count := range counts {
+		s.units.WithLabelValues(labels[0], labels[1]).Set(float64(count))
+	}
+
+	s.mutex.Lock()
+	s.fetchedAt = snapshot.FetchedAt
+	s.mutex.Unlock()
+}
+
+// Describe implements prometheus.Collector for the snapshot age
+func (s *Snapshots) Describe(ch chan<- *prometheus.Desc) {
+	ch <- snapshotAgeDesc
+}
+
+// Collect implements prometheus.Collector reporting the snapshot age only once there is a snapshot
+func (s *Snapshots) Collect(ch chan<- prometheus.Metric) {
+	s.mutex.RLock()
+	fetchedAt := s.fetchedAt
+	s.mutex.RUnlock()
+	if fetchedAt.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(snapshotAgeDesc, prometheus.GaugeValue, time.Since(fetchedAt).Seconds())
+}
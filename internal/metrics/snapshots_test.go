@@ -0,0 +1,31 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/metrics"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ poller.Listener = (&metrics.Snapshots{}).Record
+
+func TestSnapshots_CountsUnitsByStatusAndRegion(t *testing.T) {
+	r := metrics.NewRegistry()
+	s := metrics.NewSnapshots(r)
+	assert.NotContains(t, collect(t, r), "\nonde2adose_snapshot_age_seconds ", "expected no age before the first snapshot")
+
+	s.Record(context.Background(), &poller.Snapshot{FetchedAt: time.Now().Add(-time.Minute), Units: []*prefeitura.DeOlhoNaFilaUnit{
+		{IDStr: "1", LineIndexStr: "1", RegionIDStr: "5"},
+		{IDStr: "2", LineIndexStr: "1", RegionIDStr: "5"},
+		{IDStr: "3", LineIndexStr: "4", RegionIDStr: "1"},
+	}})
+
+	output := collect(t, r)
+	assert.Contains(t, output, `onde2adose_units{line_status="no_line",region="south"} 2`, "expected count to match")
+	assert.Contains(t, output, `onde2adose_units{line_status="large",region="center"} 1`, "expected count to match")
+	assert.Contains(t, output, "onde2adose_snapshot_age_seconds 6", "expected age to be about a minute")
+}
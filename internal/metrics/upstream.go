@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// payloadBuckets go from 1KiB to 16MiB
+var payloadBuckets = []float64{1 << 10, 1 << 12, 1 << 14, 1 << 16, 1 << 18, 1 << 20, 1 << 22, 1 << 24}
+
+// ErrorClass groups fetch errors into a small set of label values
+func ErrorClass(err error) string {
+	var (
+		timeoutErr  *prefeitura.TimeoutError
+		canceledErr *prefeitura.CanceledError
+		statusErr   *prefeitura.StatusError
+		emptyErr    *prefeitura.EmptyBodyError
+		decodeErr   *prefeitura.DecodeError
+	)
+	switch {
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.As(err, &canceledErr):
+		return "canceled"
+	case errors.As(err, &statusErr):
+		return "status"
+	case errors.As(err, &emptyErr):
+		return "empty_body"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	case errors.Is(err, breaker.ErrOpen):
+		return "circuit_open"
+	default:
+		return "other"
+	}
+}
+
+// DeOlhoNaFila measures the latency and errors of fetches from a source. It should wrap the circuit
+// breaker so that fetches refused by an open circuit are counted too.
+type DeOlhoNaFila struct {
+	source   deps.DeOlhoNaFila
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewDeOlhoNaFila wraps source registering its metrics in r
+func NewDeOlhoNaFila(source deps.DeOlhoNaFila, r prometheus.Registerer) *DeOlhoNaFila {
+	factory := promauto.With(r)
+	return &DeOlhoNaFila{
+		source: source,
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "onde2adose_upstream_fetch_duration_seconds",
+			Help:    "Duration of fetches from De Olho Na Fila, including retries.",
+			Buckets: DefaultDurationBuckets,
+		}, []string{"outcome"}),
+		errors: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "onde2adose_upstream_fetch_errors_total",
+			Help: "Failed fetches from De Olho Na Fila by class of error.",
+		}, []string{"class"}),
+	}
+}
+
+// Fetch fetches from the source recording how long it took and why it failed
+func (m *DeOlhoNaFila) Fetch(ctx context.Context) ([]*prefeituradeps.DeOlhoNaFilaUnit, error) {
+	start := time.Now()
+	units, err := m.source.Fetch(ctx)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		m.errors.WithLabelValues(ErrorClass(err)).Inc()
+	}
+	m.duration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	return units, err
+}
+
+// HTTPClient measures the size of response bodies read through it
+type HTTPClient struct {
+	client deps.HTTPClient
+	size   prometheus.Histogram
+}
+
+// NewHTTPClient wraps client registering the payload size metric in r
+func NewHTTPClient(client deps.HTTPClient, r prometheus.Registerer) *HTTPClient {
+	return &HTTPClient{
+		client: client,
+		size: promauto.With(r).NewHistogram(prometheus.HistogramOpts{
+			Name:    "onde2adose_upstream_payload_bytes",
+			Help:    "Size of the response bodies received from De Olho Na Fila.",
+			Buckets: payloadBuckets,
+		}),
+	}
+}
+
+// Do sends the request and records the size of successful response bodies once they're closed. Error
+// responses are left out since they're usually closed without being read.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil || resp.Body == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, observe: func(n int64) { c.size.Observe(float64(n)) }}
+	return resp, nil
+}
+
+// countingBody counts the bytes read and reports them when closed
+type countingBody struct {
+	io.ReadCloser
+	observe func(int64)
+
+	read int64
+	once sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.observe(b.read) })
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,125 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ deps.DeOlhoNaFila = &metrics.DeOlhoNaFila{}
+	_ deps.HTTPClient   = &metrics.HTTPClient{}
+)
+
+func TestErrorClass_GroupsFetchErrors(t *testing.T) {
+	cases := map[string]error{
+		"timeout":      &prefeitura.TimeoutError{Err: errors.New("slow")},
+		"canceled":     &prefeitura.CanceledError{Err: context.Canceled},
+		"status":       &prefeitura.StatusError{StatusCode: 502},
+		"empty_body":   &prefeitura.EmptyBodyError{},
+		"decode":       &prefeitura.DecodeError{Err: errors.New("bad")},
+		"circuit_open": breaker.ErrOpen,
+		"other":        errors.New("boom"),
+	}
+	for expected, err := range cases {
+		assert.Equal(t, expected, metrics.ErrorClass(err), "expected class of %v to match", err)
+	}
+}
+
+func TestDeOlhoNaFila_RecordsLatencyAndErrors(t *testing.T) {
+	r := metrics.NewRegistry()
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeituradeps.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturnsOnCall(1, nil, &prefeitura.TimeoutError{Err: errors.New("slow")})
+	m := metrics.NewDeOlhoNaFila(source, r)
+
+	units, err := m.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 1, "expected units to be passed through")
+	_, err = m.Fetch(context.Background())
+	require.Error(t, err, "expected error to be passed through")
+
+	output := collect(t, r)
+	assert.Contains(t, output, `onde2adose_upstream_fetch_duration_seconds_count{outcome="success"} 1`, "expected successful fetch to be measured")
+	assert.Contains(t, output, `onde2adose_upstream_fetch_duration_seconds_count{outcome="error"} 1`, "expected failed fetch to be measured")
+	assert.Contains(t, output, `onde2adose_upstream_fetch_errors_total{class="timeout"} 1`, "expected error to be classified")
+}
+
+func TestDeOlhoNaFila_RecordsFetchesRefusedByAnOpenCircuit(t *testing.T) {
+	r := metrics.NewRegistry()
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns(nil, errors.New("boom"))
+	m := metrics.NewDeOlhoNaFila(breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: time.Hour}), r)
+
+	_, err := m.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	_, err = m.Fetch(context.Background())
+	require.ErrorIs(t, err, breaker.ErrOpen, "expected circuit to be open")
+
+	output := collect(t, r)
+	assert.Contains(t, output, `onde2adose_upstream_fetch_errors_total{class="other"} 1`, "expected source error to be classified")
+	assert.Contains(t, output, `onde2adose_upstream_fetch_errors_total{class="circuit_open"} 1`, "expected refused fetch to be classified")
+}
+
+func TestHTTPClient_RecordsPayloadSizeWhenBodyIsClosed(t *testing.T) {
+	r := metrics.NewRegistry()
+	client := &dependenciesfakes.FakeHTTPClient{}
+	client.DoReturns(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 2000)))}, nil)
+	m := metrics.NewHTTPClient(client, r)
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err, "expected error to match")
+	resp, err := m.Do(req)
+	require.NoError(t, err, "expected error to match")
+	_, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, resp.Body.Close(), "expected error to match")
+	require.NoError(t, resp.Body.Close(), "expected error to match")
+
+	output := collect(t, r)
+	assert.Contains(t, output, "onde2adose_upstream_payload_bytes_sum 2000\n", "expected size to match")
+	assert.Contains(t, output, "onde2adose_upstream_payload_bytes_count 1\n", "expected body to be measured once")
+}
+
+func TestHTTPClient_LeavesNilBodiesAlone(t *testing.T) {
+	r := metrics.NewRegistry()
+	client := &dependenciesfakes.FakeHTTPClient{}
+	client.DoReturns(&http.Response{StatusCode: http.StatusOK}, nil)
+	m := metrics.NewHTTPClient(client, r)
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err, "expected error to match")
+	resp, err := m.Do(req)
+	require.NoError(t, err, "expected error to match")
+
+	assert.Nil(t, resp.Body, "expected body to stay nil")
+	assert.Contains(t, collect(t, r), "onde2adose_upstream_payload_bytes_count 0\n", "expected no size to be recorded")
+}
+
+func TestHTTPClient_DoesNotRecordErrorResponses(t *testing.T) {
+	r := metrics.NewRegistry()
+	client := &dependenciesfakes.FakeHTTPClient{}
+	client.DoReturns(&http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(strings.NewReader("unavailable"))}, nil)
+	m := metrics.NewHTTPClient(client, r)
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err, "expected error to match")
+	resp, err := m.Do(req)
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, resp.Body.Close(), "expected error to match")
+
+	assert.Contains(t, collect(t, r), "onde2adose_upstream_payload_bytes_count 0\n", "expected error response not to be measured")
+}
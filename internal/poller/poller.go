@@ -0,0 +1,211 @@
+package poller
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+// Snapshot is the result of a successful fetch from the source
+type Snapshot struct {
+	Units     []*prefeitura.DeOlhoNaFilaUnit
+	FetchedAt time.Time
+}
+
+// Status summarizes the refreshes made by a poller
+type Status struct {
+	// FetchedAt is when the current snapshot was fetched. It is zero until the first successful fetch.
+	FetchedAt time.Time
+	Units     int
+	// LastError is the error of the latest failed refresh, kept after later successes
+	LastError   error
+	LastErrorAt time.Time
+}
+
+// Listener is notified of every new snapshot. Listeners are called one snapshot at a time, in the
+// order snapshots were fetched, by the goroutine running Run so they should hand off slow work.
+type Listener func(ctx context.Context, snapshot *Snapshot)
+
+// Poller periodically fetches data from a source and keeps the latest result in memory.
+// It implements dependencies.DeOlhoNaFila so handlers can be served from the latest snapshot, and
+// owns how stale the data they serve can get.
+type Poller struct {
+	source   deps.DeOlhoNaFila
+	interval time.Duration
+	ll       *log.Logger
+
+	mutex       sync.RWMutex
+	snapshot    *Snapshot
+	lastError   error
+	lastErrorAt time.Time
+	listeners   []Listener
+	inflight    *fetchCall
+	maxStale    time.Duration
+	// runCtx is the context of Run so that stopping it cancels the fetch in progress
+	runCtx context.Context
+
+	notifyMutex sync.Mutex
+	notified    *Snapshot
+}
+
+type fetchCall struct {
+	done     chan struct{}
+	snapshot *Snapshot
+	err      error
+}
+
+// New creates a poller that refreshes data from source every interval once started
+func New(source deps.DeOlhoNaFila, interval time.Duration, ll *log.Logger) *Poller {
+	return &Poller{source: source, interval: interval, ll: ll, runCtx: context.Background()}
+}
+
+// AddListener registers l to be notified of every successful refresh
+func (p *Poller) AddListener(l Listener) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.listeners = append(p.listeners, l)
+}
+
+// SetMaxStale limits how long after the interval Fetch serves a snapshot while refreshes fail. Past it,
+// Fetch waits for a refresh and returns its error. Zero, the default, serves the latest snapshot forever.
+func (p *Poller) SetMaxStale(maxStale time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.maxStale = maxStale
+}
+
+// Run refreshes the data immediately and then on every interval until ctx is done, which also cancels
+// the fetch in progress
+func (p *Poller) Run(ctx context.Context) {
+	p.mutex.Lock()
+	p.runCtx = ctx
+	p.mutex.Unlock()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			p.ll.Println("error refreshing data:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh fetches data from the source, replaces the current snapshot when it succeeds and notifies
+// the listeners. It is called by Run, which is the only caller outside of tests.
+func (p *Poller) Refresh(ctx context.Context) error {
+	snapshot, err := p.refresh(ctx)
+	if err != nil {
+		return err
+	}
+	p.notify(ctx, snapshot)
+	return nil
+}
+
+// Snapshot returns the latest snapshot or nil if no fetch succeeded yet
+func (p *Poller) Snapshot() *Snapshot {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.snapshot
+}
+
+// Status reports the latest snapshot and the latest error
+func (p *Poller) Status() Status {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	status := Status{LastError: p.lastError, LastErrorAt: p.lastErrorAt}
+	if p.snapshot != nil {
+		status.FetchedAt = p.snapshot.FetchedAt
+		status.Units = len(p.snapshot.Units)
+	}
+	return status
+}
+
+// Fetch returns the units of the latest snapshot. When there isn't one yet or it is too stale, it waits
+// for a fetch shared with every concurrent caller. Listeners are left to be notified by Run.
+func (p *Poller) Fetch(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	if snapshot := p.usableSnapshot(); snapshot != nil {
+		return snapshot.Units, nil
+	}
+	snapshot, err := p.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Units, nil
+}
+
+// usableSnapshot returns the latest snapshot unless it is older than the interval plus the max staleness
+func (p *Poller) usableSnapshot() *Snapshot {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.snapshot == nil || (p.maxStale > 0 && time.Since(p.snapshot.FetchedAt) >= p.interval+p.maxStale) {
+		return nil
+	}
+	return p.snapshot
+}
+
+// refresh joins the fetch in progress or starts a new one and waits for it
+func (p *Poller) refresh(ctx context.Context) (*Snapshot, error) {
+	p.mutex.Lock()
+	call := p.inflight
+	if call == nil {
+		call = &fetchCall{done: make(chan struct{})}
+		p.inflight = call
+		go p.fetch(p.runCtx, call)
+	}
+	p.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.snapshot, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch gets the data from the source within one interval, so that a slow upstream, retries included,
+// doesn't hold callers past the next refresh. The fetch is shared so it can't be bound to a single
+// caller's context.
+func (p *Poller) fetch(parent context.Context, call *fetchCall) {
+	ctx, cancel := context.WithTimeout(parent, p.interval)
+	defer cancel()
+	units, err := p.source.Fetch(ctx)
+
+	p.mutex.Lock()
+	if err != nil {
+		p.lastError, p.lastErrorAt = err, time.Now()
+	} else {
+		call.snapshot = &Snapshot{Units: units, FetchedAt: time.Now()}
+		p.snapshot = call.snapshot
+	}
+	call.err = err
+	p.inflight = nil
+	p.mutex.Unlock()
+	close(call.done)
+}
+
+// notify calls the listeners with snapshot unless they were already notified of it or of a newer one
+func (p *Poller) notify(ctx context.Context, snapshot *Snapshot) {
+	p.notifyMutex.Lock()
+	defer p.notifyMutex.Unlock()
+	if p.notified != nil && !snapshot.FetchedAt.After(p.notified.FetchedAt) {
+		return
+	}
+	p.notified = snapshot
+
+	p.mutex.RLock()
+	listeners := p.listeners
+	p.mutex.RUnlock()
+	for _, l := range listeners {
+		l(ctx, snapshot)
+	}
+}
@@ -0,0 +1,254 @@
+package poller_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.DeOlhoNaFila = &poller.Poller{}
+
+var discardLogger = log.New(ioutil.Discard, "", 0)
+
+func TestPoller_FetchFallsBackToSourceWithoutSnapshot(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns([]*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	p := poller.New(source, time.Minute, discardLogger)
+
+	units, err := p.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 1, "expected length to match")
+	assert.Equal(t, 1, source.FetchCallCount(), "expected source to be called")
+	assert.NotNil(t, p.Snapshot(), "expected synchronous fetch to be kept as snapshot")
+}
+
+func TestPoller_FetchSharesASingleSourceCallWithoutSnapshot(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-release
+		return []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil
+	}
+	p := poller.New(source, time.Minute, discardLogger)
+	notified := 0
+	p.AddListener(func(context.Context, *poller.Snapshot) { notified++ })
+
+	results := make(chan []*prefeitura.DeOlhoNaFilaUnit)
+	for i := 0; i < 5; i++ {
+		go func() {
+			units, _ := p.Fetch(context.Background())
+			results <- units
+		}()
+	}
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 1 }, time.Second, time.Millisecond, "expected source to be called")
+	close(release)
+	for i := 0; i < 5; i++ {
+		assert.Len(t, <-results, 1, "expected every caller to get the units")
+	}
+	assert.Equal(t, 1, source.FetchCallCount(), "expected concurrent callers to share the source call")
+	assert.Equal(t, 0, notified, "expected listeners to be notified only by refreshes")
+}
+
+func TestPoller_FetchServesSnapshotWithoutCallingSource(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns([]*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}, {IDStr: "2"}}, nil)
+	p := poller.New(source, time.Minute, discardLogger)
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+
+	units, err := p.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 2, "expected length to match")
+	assert.Equal(t, 1, source.FetchCallCount(), "expected source to be called only by refresh")
+}
+
+func TestPoller_FetchErrorsWhenSourceErrorsWithoutSnapshot(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns(nil, errors.New("boom"))
+	p := poller.New(source, time.Minute, discardLogger)
+
+	_, err := p.Fetch(context.Background())
+	require.Error(t, err, "expected error to match")
+	assert.Nil(t, p.Snapshot(), "expected no snapshot")
+}
+
+func TestPoller_RefreshFailureKeepsPreviousSnapshot(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturnsOnCall(1, nil, errors.New("boom"))
+	p := poller.New(source, time.Minute, discardLogger)
+
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+	require.Error(t, p.Refresh(context.Background()), "expected error to match")
+
+	units, err := p.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 1, "expected previous snapshot to be served")
+}
+
+func TestPoller_FetchRefusesSnapshotPastMaxStale(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturnsOnCall(1, nil, errors.New("boom"))
+	source.FetchReturnsOnCall(2, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}, {IDStr: "2"}}, nil)
+	p := poller.New(source, 10*time.Millisecond, discardLogger)
+	p.SetMaxStale(10 * time.Millisecond)
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+
+	units, err := p.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 1, "expected recent snapshot to be served")
+	assert.Equal(t, 1, source.FetchCallCount(), "expected recent snapshot to be served without calling the source")
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = p.Fetch(context.Background())
+	assert.EqualError(t, err, "boom", "expected refresh error past the max staleness")
+	units, err = p.Fetch(context.Background())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, units, 2, "expected refreshed units")
+}
+
+func TestPoller_OpenCircuitKeepsPreviousSnapshotAndFetchTime(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturns(nil, errors.New("boom"))
+	p := poller.New(breaker.NewDeOlhoNaFila(source, breaker.Settings{FailureThreshold: 1, ResetTimeout: time.Hour}), time.Minute, discardLogger)
+
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+	snapshot := p.Snapshot()
+	require.Error(t, p.Refresh(context.Background()), "expected error to match")
+
+	require.ErrorIs(t, p.Refresh(context.Background()), breaker.ErrOpen, "expected open circuit to fail the refresh")
+	assert.Same(t, snapshot, p.Snapshot(), "expected previous snapshot to be kept")
+	assert.Equal(t, snapshot.FetchedAt, p.Status().FetchedAt, "expected fetch time not to be refreshed")
+}
+
+func TestPoller_RunRefreshesOnIntervalUntilCanceled(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturns([]*prefeitura.DeOlhoNaFilaUnit{}, nil)
+	p := poller.New(source, 5*time.Millisecond, discardLogger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return source.FetchCallCount() >= 3 }, time.Second, time.Millisecond, "expected periodic refreshes")
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected poller to stop after cancellation")
+	}
+}
+
+func TestPoller_RefreshGivesUpOnSlowSourceAfterInterval(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchStub = func(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	p := poller.New(source, 20*time.Millisecond, discardLogger)
+
+	done := make(chan error)
+	go func() { done <- p.Refresh(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "expected fetch to time out")
+	case <-time.After(time.Second):
+		t.Fatal("expected refresh to give up after the interval")
+	}
+}
+
+func TestPoller_StoppingRunCancelsFetchInProgress(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	canceled := make(chan error, 1)
+	source.FetchStub = func(ctx context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	p := poller.New(source, time.Hour, discardLogger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Run(ctx)
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 1 }, time.Second, time.Millisecond, "expected source to be called")
+	cancel()
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.Canceled, "expected fetch to be canceled with Run")
+	case <-time.After(time.Second):
+		t.Fatal("expected fetch in progress to be canceled")
+	}
+}
+
+func TestPoller_RefreshNotifiesListeners(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}}, nil)
+	source.FetchReturnsOnCall(1, nil, errors.New("boom"))
+	p := poller.New(source, time.Minute, discardLogger)
+	var snapshots []*poller.Snapshot
+	p.AddListener(func(_ context.Context, s *poller.Snapshot) {
+		snapshots = append(snapshots, s)
+	})
+
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+	require.Error(t, p.Refresh(context.Background()), "expected error to match")
+
+	require.Len(t, snapshots, 1, "expected only successful refreshes to be notified")
+	assert.Equal(t, p.Snapshot(), snapshots[0], "expected snapshot to match")
+}
+
+func TestPoller_RefreshNotifiesSharedSnapshotOnce(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	release := make(chan struct{})
+	source.FetchStub = func(context.Context) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+		<-release
+		return []*prefeitura.DeOlhoNaFilaUnit{}, nil
+	}
+	p := poller.New(source, time.Minute, discardLogger)
+	notified := make(chan *poller.Snapshot, 2)
+	p.AddListener(func(_ context.Context, s *poller.Snapshot) { notified <- s })
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- p.Refresh(context.Background()) }()
+	}
+	require.Eventually(t, func() bool { return source.FetchCallCount() == 1 }, time.Second, time.Millisecond, "expected source to be called")
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-done, "expected error to match")
+	require.NoError(t, <-done, "expected error to match")
+
+	assert.Equal(t, 1, source.FetchCallCount(), "expected concurrent refreshes to share the source call")
+	assert.Len(t, notified, 1, "expected the shared snapshot to be notified once")
+}
+
+func TestPoller_StatusReportsSnapshotAndLatestError(t *testing.T) {
+	source := &dependenciesfakes.FakeDeOlhoNaFila{}
+	source.FetchReturnsOnCall(0, nil, errors.New("boom"))
+	source.FetchReturnsOnCall(1, []*prefeitura.DeOlhoNaFilaUnit{{IDStr: "1"}, {IDStr: "2"}}, nil)
+	p := poller.New(source, time.Minute, discardLogger)
+	assert.True(t, p.Status().FetchedAt.IsZero(), "expected no fetch before refreshing")
+
+	require.Error(t, p.Refresh(context.Background()), "expected error to match")
+	require.NoError(t, p.Refresh(context.Background()), "expected error to match")
+
+	status := p.Status()
+	assert.Equal(t, p.Snapshot().FetchedAt, status.FetchedAt, "expected fetch time to match")
+	assert.Equal(t, 2, status.Units, "expected unit count to match")
+	assert.EqualError(t, status.LastError, "boom", "expected latest error to be kept")
+	assert.False(t, status.LastErrorAt.IsZero(), "expected error time to be set")
+}
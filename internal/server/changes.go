@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+)
+
+// ChangeLog provides the changes detected between snapshots
+type ChangeLog interface {
+	Since(since time.Time) []*changes.Event
+	Subscribe(afterID int64) ([]*changes.Event, <-chan *changes.Event, func())
+}
+
+func (h *httpHandler) changes(w http.ResponseWriter, req *http.Request) {
+	since, err := time.Parse(time.RFC3339, req.URL.Query().Get("since"))
+	if err != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", map[string]string{
+			"since": "must be an RFC 3339 timestamp",
+		})
+		return
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err = json.NewEncoder(w).Encode(h.ChangeLog.Since(since))
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
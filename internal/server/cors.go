@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AnyOrigin allows browsers on every origin to call the API
+	AnyOrigin = "*"
+
+	corsMaxAge = 10 * time.Minute
+)
+
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	corsAllowedHeaders = []string{"Content-Type", "Accept", "Authorization", LastEventIDHeader, RequestIDHeader}
+)
+
+// corsMiddleware lets browsers on origins call the API. Preflight requests are answered
+// directly since routes only accept the methods they serve.
+func corsMiddleware(origins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if len(origin) == 0 || !(allowed[AnyOrigin] || allowed[origin]) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if allowed[AnyOrigin] {
+				w.Header().Set("Access-Control-Allow-Origin", AnyOrigin)
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+			if req.Method != http.MethodOptions || len(req.Header.Get("Access-Control-Request-Method")) == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
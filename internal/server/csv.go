@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+const (
+	csvFilename = "onde-2a-dose.csv"
+	// csvFlushEvery is the number of rows written between flushes to the client
+	csvFlushEvery = 50
+)
+
+// utf8BOM lets spreadsheet software like Excel detect that the file is UTF-8 encoded
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var csvHeader = []string{
+	"id",
+	"name",
+	"address",
+	"post_type",
+	"neighborhood_id",
+	"neighborhood",
+	"region_id",
+	"region",
+	"last_updated_at",
+	"line_index",
+	"line_status",
+	string(domain.VaccineCoronaVac),
+	string(domain.VaccineAstraZeneca),
+	string(domain.VaccinePfizer),
+	"latitude",
+	"longitude",
+}
+
+// writeCSV streams one row per unit converted to the domain model
+func (h *httpHandler) writeCSV(ctx context.Context, w http.ResponseWriter, units []*prefeituradeps.DeOlhoNaFilaUnit, bom bool) {
+	w.Header().Add(prefeitura.ContentTypeHeader, CSVContentType)
+	w.Header().Add("Content-Disposition", `attachment; filename="`+csvFilename+`"`)
+	if bom {
+		w.Write(utf8BOM)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	for i, raw := range units {
+		if ctx.Err() != nil {
+			return
+		}
+		unit, _ := domain.FromDeOlhoNaFila(raw)
+		unit.Coordinates = h.coordinates(raw)
+		if err := writer.Write(csvRow(unit)); err != nil {
+			return
+		}
+		if flusher != nil && (i+1)%csvFlushEvery == 0 {
+			writer.Flush()
+			flusher.Flush()
+		}
+	}
+	writer.Flush()
+}
+
+func csvRow(unit *domain.Unit) []string {
+	lastUpdatedAt := ""
+	if !unit.LastUpdatedAt.IsZero() {
+		lastUpdatedAt = unit.LastUpdatedAt.Format(time.RFC3339)
+	}
+	latitude, longitude := "", ""
+	if unit.Coordinates != nil {
+		latitude = strconv.FormatFloat(unit.Latitude, 'f', -1, 64)
+		longitude = strconv.FormatFloat(unit.Longitude, 'f', -1, 64)
+	}
+	return []string{
+		strconv.Itoa(unit.ID),
+		unit.Name,
+		unit.Address,
+		string(unit.PostType),
+		strconv.Itoa(unit.NeighborhoodID),
+		unit.Neighborhood,
+		strconv.Itoa(unit.RegionID),
+		string(unit.Region),
+		lastUpdatedAt,
+		strconv.Itoa(unit.LineIndex),
+		string(unit.LineStatus),
+		strconv.FormatBool(unit.Vaccines.Has(domain.VaccineCoronaVac)),
+		strconv.FormatBool(unit.Vaccines.Has(domain.VaccineAstraZeneca)),
+		strconv.FormatBool(unit.Vaccines.Has(domain.VaccinePfizer)),
+		latitude,
+		longitude,
+	}
+}
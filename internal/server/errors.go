@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+)
+
+const (
+	// ProblemJSONContentType is the RFC 7807 content type for problem details
+	ProblemJSONContentType = "application/problem+json; charset=UTF-8"
+
+	problemJSONMediaType = "application/problem+json"
+)
+
+// Stable error codes returned in error responses
+const (
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeUnauthorized        = "unauthorized"
+	ErrorCodeMethodNotAllowed    = "method_not_allowed"
+	ErrorCodeMissingBody         = "missing_body"
+	ErrorCodeInvalidBody         = "invalid_body"
+	ErrorCodeInvalidFilter       = "invalid_filter"
+	ErrorCodeInvalidParameter    = "invalid_parameter"
+	ErrorCodeUpstreamTimeout     = "upstream_timeout"
+	ErrorCodeUpstreamUnavailable = "upstream_unavailable"
+	ErrorCodeUpstreamError       = "upstream_error"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// ErrorResponse is the body of error responses
+type ErrorResponse struct {
+	Error *ErrorBody `json:"error"`
+}
+
+// ErrorBody describes an error with a stable machine readable code and a human message
+type ErrorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Problem is the RFC 7807 form of an error response, returned when requested through the Accept header
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    interface{} `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// fetchErrorStatus maps errors from fetching upstream data to the status and error code that best describe them
+func fetchErrorStatus(err error) (int, string) {
+	var (
+		timeoutErr  *prefeitura.TimeoutError
+		canceledErr *prefeitura.CanceledError
+		statusErr   *prefeitura.StatusError
+		emptyErr    *prefeitura.EmptyBodyError
+		decodeErr   *prefeitura.DecodeError
+	)
+	switch {
+	case errors.As(err, &timeoutErr):
+		return http.StatusGatewayTimeout, ErrorCodeUpstreamTimeout
+	case errors.Is(err, breaker.ErrOpen), errors.As(err, &canceledErr):
+		return http.StatusServiceUnavailable, ErrorCodeUpstreamUnavailable
+	case errors.As(err, &statusErr), errors.As(err, &emptyErr), errors.As(err, &decodeErr):
+		return http.StatusBadGateway, ErrorCodeUpstreamError
+	default:
+		return http.StatusInternalServerError, ErrorCodeInternal
+	}
+}
+
+func (h *httpHandler) writeFetchError(w http.ResponseWriter, req *http.Request, err error) {
+	statusCode, code := fetchErrorStatus(err)
+	h.writeError(w, req, statusCode, code, "error fetching data", err)
+}
+
+// writeError logs err, which may hold upstream URLs or other internals, and only returns the code and message
+func (h *httpHandler) writeError(w http.ResponseWriter, req *http.Request, statusCode int, code string, message string, err error) {
+	if err != nil {
+		h.Logger.Printf("request %s: %s: %v", RequestIDFrom(req.Context()), message, err)
+	}
+	writeErrorDetails(w, req, statusCode, code, message, nil)
+}
+
+func writeErrorDetails(w http.ResponseWriter, req *http.Request, statusCode int, code string, message string, details interface{}) {
+	requestID := RequestIDFrom(req.Context())
+
+	var body interface{} = &ErrorResponse{Error: &ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID,
+	}}
+	contentType := JSONContentType
+	if accepts(req, problemJSONMediaType) {
+		contentType = ProblemJSONContentType
+		body = &Problem{
+			Type:      "about:blank",
+			Title:     message,
+			Status:    statusCode,
+			Detail:    details,
+			Instance:  req.URL.Path,
+			Code:      code,
+			RequestID: requestID,
+		}
+	}
+
+	w.Header().Set(prefeitura.ContentTypeHeader, contentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func notFound(w http.ResponseWriter, req *http.Request) {
+	writeErrorDetails(w, req, http.StatusNotFound, ErrorCodeNotFound, "not found", nil)
+}
+
+func unauthorized(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="webhooks"`)
+	writeErrorDetails(w, req, http.StatusUnauthorized, ErrorCodeUnauthorized, "unauthorized", nil)
+}
+
+func methodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	writeErrorDetails(w, req, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "method not allowed", nil)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+const (
+	EventStreamContentType = "text/event-stream"
+
+	// LastEventIDHeader is sent by EventSource clients when reconnecting
+	LastEventIDHeader = "Last-Event-ID"
+
+	// heartbeatInterval keeps idle connections from being closed by proxies
+	heartbeatInterval = 30 * time.Second
+	// reconnectDelay is how long clients wait before reconnecting to a closed stream
+	reconnectDelay = time.Second
+	// streamWriteTimeout bounds each write to a stream, which replaces the server write timeout
+	streamWriteTimeout = 10 * time.Second
+)
+
+type connContextKey struct{}
+
+// ConnContext keeps the connection of each request in its context so that event streams can outlive
+// the server write timeout, which covers the whole response. It is meant for http.Server.ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// extendWriteDeadline gives the next write to the response of req streamWriteTimeout to complete.
+// Without the connection in the context the server write timeout still applies.
+func extendWriteDeadline(req *http.Request) {
+	if conn, ok := req.Context().Value(connContextKey{}).(net.Conn); ok {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	}
+}
+
+// parseEventFilter reads the event filters from query. Parameters may be repeated or comma separated.
+// The second return value maps each invalid parameter to the reason it was rejected and is nil when every
+// parameter is valid.
+func parseEventFilter(query url.Values) (*changes.Filter, map[string]string) {
+	invalid := map[string]string{}
+	filter := &changes.Filter{}
+
+	var ok bool
+	if filter.RegionIDs, ok = parseIDList(query, "crs"); !ok {
+		invalid["crs"] = "must be a list of positive integers"
+	}
+	if filter.NeighborhoodIDs, ok = parseIDList(query, "distrito"); !ok {
+		invalid["distrito"] = "must be a list of positive integers"
+	}
+	if filter.UnitIDs, ok = parseIDList(query, "unit"); !ok {
+		invalid["unit"] = "must be a list of positive integers"
+	}
+	for _, v := range splitList(query, "vaccine") {
+		vaccine := domain.Vaccine(v)
+		if !isKnownVaccine(vaccine) {
+			invalid["vaccine"] = "must be a list of coronavac, astrazeneca or pfizer"
+			break
+		}
+		filter.Vaccines = append(filter.Vaccines, vaccine)
+	}
+
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+	return filter, nil
+}
+
+func splitList(query url.Values, name string) []string {
+	values := []string{}
+	for _, v := range query[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+func parseIDList(query url.Values, name string) ([]int, bool) {
+	var ids []int
+	for _, v := range splitList(query, name) {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+func isKnownVaccine(vaccine domain.Vaccine) bool {
+	for _, v := range domain.Vaccines {
+		if v == vaccine {
+			return true
+		}
+	}
+	return false
+}
+
+// events streams the changes detected on each refresh as Server-Sent Events
+func (h *httpHandler) events(w http.ResponseWriter, req *http.Request) {
+	filter, invalid := parseEventFilter(req.URL.Query())
+	if invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidFilter, "invalid filter", invalid)
+		return
+	}
+	lastEventID := int64(-1)
+	if v := req.Header.Get(LastEventIDHeader); len(v) > 0 {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", map[string]string{
+				LastEventIDHeader: "must be a non negative integer",
+			})
+			return
+		}
+		lastEventID = id
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "streaming not supported", nil)
+		return
+	}
+
+	backlog, events, cancel := h.ChangeLog.Subscribe(lastEventID)
+	defer cancel()
+
+	extendWriteDeadline(req)
+	w.Header().Set(prefeitura.ContentTypeHeader, EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	// Connections are closed on shutdown and when clients fall behind so they should reconnect quickly
+	fmt.Fprintf(w, "retry: %d\n: connected\n\n", reconnectDelay.Milliseconds())
+	flusher.Flush()
+
+	for _, event := range backlog {
+		if filter.Match(event) {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-h.streamsClosed:
+			return
+		case <-heartbeat.C:
+			extendWriteDeadline(req)
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, open := <-events:
+			if !open {
+				// The client fell behind and should reconnect from the last event it received
+				return
+			}
+			if !filter.Match(event) {
+				continue
+			}
+			extendWriteDeadline(req)
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event *changes.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
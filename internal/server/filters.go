@@ -0,0 +1,141 @@
+package server
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+// unitFilter reports whether a unit should be kept
+type unitFilter func(*prefeituradeps.DeOlhoNaFilaUnit) bool
+
+// unitFilters are combined with AND semantics
+type unitFilters []unitFilter
+
+func (filters unitFilters) apply(units []*prefeituradeps.DeOlhoNaFilaUnit) []*prefeituradeps.DeOlhoNaFilaUnit {
+	if len(filters) == 0 {
+		return units
+	}
+	results := make([]*prefeituradeps.DeOlhoNaFilaUnit, 0, len(units))
+	for _, unit := range units {
+		if filters.match(unit) {
+			results = append(results, unit)
+		}
+	}
+	return results
+}
+
+func (filters unitFilters) match(unit *prefeituradeps.DeOlhoNaFilaUnit) bool {
+	for _, filter := range filters {
+		if !filter(unit) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterParser builds filters from query parameters, collecting the reason each invalid parameter was rejected
+type filterParser struct {
+	query   url.Values
+	filters unitFilters
+	invalid map[string]string
+}
+
+// parseUnitFilters reads the filters supported on unit listings from query. The second return value
+// maps each invalid parameter to the reason it was rejected and is nil when every parameter is valid.
+func parseUnitFilters(query url.Values) (unitFilters, map[string]string) {
+	p := &filterParser{query: query, invalid: map[string]string{}}
+
+	p.idOrName("crs", (*prefeituradeps.DeOlhoNaFilaUnit).RegionID, func(u *prefeituradeps.DeOlhoNaFilaUnit) string { return u.RegionName })
+	p.idOrName("distrito", (*prefeituradeps.DeOlhoNaFilaUnit).NeighborhoodID, func(u *prefeituradeps.DeOlhoNaFilaUnit) string { return u.NeighborhoodName })
+	p.idOrName("tipo_posto", (*prefeituradeps.DeOlhoNaFilaUnit).TypeID, func(u *prefeituradeps.DeOlhoNaFilaUnit) string { return u.TypeName })
+	p.lineStatus("status_fila")
+	p.maxLineIndex("indice_fila")
+	p.vaccine("coronavac", (*prefeituradeps.DeOlhoNaFilaUnit).HasCoronaVac)
+	p.vaccine("astrazeneca", (*prefeituradeps.DeOlhoNaFilaUnit).HasAstraZeneca)
+	p.vaccine("pfizer", (*prefeituradeps.DeOlhoNaFilaUnit).HasPfizer)
+
+	if len(p.invalid) > 0 {
+		return nil, p.invalid
+	}
+	return p.filters, nil
+}
+
+func (p *filterParser) value(name string) (string, bool) {
+	if _, ok := p.query[name]; !ok {
+		return "", false
+	}
+	return p.query.Get(name), true
+}
+
+// idOrName matches numeric values against the ID returned by id and other values, ignoring case,
+// against the name returned by name, such as crs=5 or crs=SUL
+func (p *filterParser) idOrName(param string, id func(*prefeituradeps.DeOlhoNaFilaUnit) int, name func(*prefeituradeps.DeOlhoNaFilaUnit) string) {
+	v, ok := p.value(param)
+	if !ok {
+		return
+	}
+	v = strings.TrimSpace(v)
+	n, err := strconv.Atoi(v)
+	if (err == nil && n < 1) || len(v) == 0 {
+		p.invalid[param] = "must be a positive integer ID or a name"
+		return
+	}
+	if err == nil {
+		p.filters = append(p.filters, func(u *prefeituradeps.DeOlhoNaFilaUnit) bool {
+			return id(u) == n
+		})
+		return
+	}
+	p.filters = append(p.filters, func(u *prefeituradeps.DeOlhoNaFilaUnit) bool {
+		return strings.EqualFold(strings.TrimSpace(name(u)), v)
+	})
+}
+
+func (p *filterParser) lineStatus(name string) {
+	v, ok := p.value(name)
+	if !ok {
+		return
+	}
+	status, known := domain.ParseLineStatus(v)
+	if !known {
+		p.invalid[name] = "must be one of no_line, small, medium, large, closed or awaiting_supply"
+		return
+	}
+	p.filters = append(p.filters, func(u *prefeituradeps.DeOlhoNaFilaUnit) bool {
+		return domain.LineStatusFromIndex(u.LineIndex()) == status
+	})
+}
+
+func (p *filterParser) maxLineIndex(name string) {
+	v, ok := p.value(name)
+	if !ok {
+		return
+	}
+	max, err := strconv.Atoi(v)
+	if err != nil || max < 1 {
+		p.invalid[name] = "must be a positive integer"
+		return
+	}
+	p.filters = append(p.filters, func(u *prefeituradeps.DeOlhoNaFilaUnit) bool {
+		return u.LineIndex() <= max
+	})
+}
+
+func (p *filterParser) vaccine(name string, accessor func(*prefeituradeps.DeOlhoNaFilaUnit) bool) {
+	v, ok := p.value(name)
+	if !ok {
+		return
+	}
+	available, err := strconv.ParseBool(v)
+	if err != nil {
+		p.invalid[name] = "must be true or false"
+		return
+	}
+	p.filters = append(p.filters, func(u *prefeituradeps.DeOlhoNaFilaUnit) bool {
+		return accessor(u) == available
+	})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+const (
+	// GeoJSONContentType is the content type of GeoJSON responses
+	GeoJSONContentType = "application/geo+json; charset=UTF-8"
+
+	geoJSONMediaType = "application/geo+json"
+
+	// CSVContentType is the content type of CSV responses
+	CSVContentType = "text/csv; charset=UTF-8"
+
+	csvMediaType = "text/csv"
+
+	formatJSON    = "json"
+	formatGeoJSON = "geojson"
+	formatCSV     = "csv"
+)
+
+// responseFormat picks the format of a unit listing from the format query parameter or the
+// Accept header. It returns false when the requested format isn't supported.
+func responseFormat(req *http.Request) (string, bool) {
+	switch format := req.URL.Query().Get("format"); format {
+	case "":
+	case formatJSON, formatGeoJSON, formatCSV:
+		return format, true
+	default:
+		return "", false
+	}
+	if accepts(req, geoJSONMediaType) {
+		return formatGeoJSON, true
+	}
+	if accepts(req, csvMediaType) {
+		return formatCSV, true
+	}
+	return formatJSON, true
+}
+
+// parseOptionalBool reads a boolean query parameter defaulting to false when absent
+func parseOptionalBool(query url.Values, name string) (bool, error) {
+	v := query.Get(name)
+	if len(v) == 0 {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// accepts returns whether mediaType is explicitly listed in the Accept header of req
+func accepts(req *http.Request, mediaType string) bool {
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		parsed, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && parsed == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// featureCollection is a GeoJSON FeatureCollection (RFC 7946)
+type featureCollection struct {
+	Type     string     `json:"type"`
+	Features []*feature `json:"features"`
+}
+
+type feature struct {
+	Type       string       `json:"type"`
+	ID         int          `json:"id"`
+	Geometry   *point       `json:"geometry"`
+	Properties *domain.Unit `json:"properties"`
+}
+
+type point struct {
+	Type string `json:"type"`
+	// Coordinates are in longitude, latitude order as required by GeoJSON
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// toFeatureCollection converts units into GeoJSON features. Units without known coordinates
+// are kept with a null geometry.
+func toFeatureCollection(units []*domain.Unit) *featureCollection {
+	collection := &featureCollection{Type: "FeatureCollection", Features: make([]*feature, 0, len(units))}
+	for _, unit := range units {
+		properties := *unit
+		properties.Coordinates = nil
+
+		f := &feature{Type: "Feature", ID: unit.ID, Properties: &properties}
+		if unit.Coordinates != nil {
+			f.Geometry = &point{Type: "Point", Coordinates: [2]float64{unit.Longitude, unit.Latitude}}
+		}
+		collection.Features = append(collection.Features, f)
+	}
+	return collection
+}
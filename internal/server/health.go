@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+)
+
+const (
+	ReadinessReady    = "ready"
+	ReadinessDegraded = "degraded"
+	ReadinessNotReady = "not_ready"
+)
+
+// Refresher reports how the data served is being refreshed
+type Refresher interface {
+	Status() poller.Status
+}
+
+// Readiness is the body of GET /readyz
+type Readiness struct {
+	Status      string     `json:"status"`
+	LastFetchAt *time.Time `json:"last_fetch_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	UnitCount   int        `json:"unit_count"`
+}
+
+func (h *httpHandler) healthz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyz fails until data was fetched once. Stale data is reported as degraded but still ready
+// since serving it is better than nothing while the city hall website is down.
+func (h *httpHandler) readyz(w http.ResponseWriter, req *http.Request) {
+	status := h.Refresher.Status()
+	readiness := &Readiness{Status: ReadinessReady, UnitCount: status.Units}
+	if status.LastError != nil {
+		readiness.LastError = status.LastError.Error()
+		readiness.LastErrorAt = &status.LastErrorAt
+	}
+
+	statusCode := http.StatusOK
+	switch {
+	case status.FetchedAt.IsZero():
+		readiness.Status = ReadinessNotReady
+		statusCode = http.StatusServiceUnavailable
+	case time.Since(status.FetchedAt) > h.ReadinessMaxAge:
+		readiness.Status = ReadinessDegraded
+	}
+	if !status.FetchedAt.IsZero() {
+		readiness.LastFetchAt = &status.FetchedAt
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(readiness)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/heatmap"
+)
+
+// Heatmap provides the typical line index of units by weekday and hour
+type Heatmap interface {
+	Unit(id int) ([]*heatmap.Cell, bool)
+	UnitIDs() []int
+}
+
+// unitHeatmap is the heatmap of a single unit
+type unitHeatmap struct {
+	UnitID int             `json:"unit_id"`
+	Cells  []*heatmap.Cell `json:"cells"`
+}
+
+func (h *httpHandler) heatmaps(w http.ResponseWriter, req *http.Request) {
+	results := []*unitHeatmap{}
+	for _, id := range h.Heatmap.UnitIDs() {
+		if cells, found := h.Heatmap.Unit(id); found && len(cells) > 0 {
+			results = append(results, &unitHeatmap{UnitID: id, Cells: cells})
+		}
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err := json.NewEncoder(w).Encode(results)
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
+
+func (h *httpHandler) unitHeatmap(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", map[string]string{
+			"id": "must be an integer",
+		})
+		return
+	}
+	cells, found := h.Heatmap.Unit(id)
+	if !found {
+		notFound(w, req)
+		return
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err = json.NewEncoder(w).Encode(&unitHeatmap{UnitID: id, Cells: cells})
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+const (
+	defaultHistoryRange = 24 * time.Hour
+	minHistoryBucket    = time.Minute
+)
+
+// historyPoint is the state of a unit's line from LastUpdatedAt until the next point
+type historyPoint struct {
+	LastUpdatedAt time.Time         `json:"last_updated_at"`
+	LineIndex     int               `json:"line_index"`
+	LineStatus    domain.LineStatus `json:"line_status"`
+	Vaccines      domain.VaccineSet `json:"vaccines"`
+}
+
+func (p *historyPoint) sameState(other *historyPoint) bool {
+	if p.LineIndex != other.LineIndex || p.LineStatus != other.LineStatus || len(p.Vaccines) != len(other.Vaccines) {
+		return false
+	}
+	for v := range p.Vaccines {
+		if !other.Vaccines.Has(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// historyQuery holds the parameters of GET /units/{id}/history
+type historyQuery struct {
+	unitID int
+	from   time.Time
+	to     time.Time
+	bucket time.Duration
+}
+
+func parseHistoryQuery(id string, query url.Values, now time.Time) (*historyQuery, map[string]string) {
+	invalid := map[string]string{}
+	q := &historyQuery{to: now}
+
+	var err error
+	q.unitID, err = strconv.Atoi(id)
+	if err != nil {
+		invalid["id"] = "must be an integer"
+	}
+	if v := query.Get("to"); len(v) > 0 {
+		q.to, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid["to"] = "must be an RFC 3339 timestamp"
+		}
+	}
+	q.from = q.to.Add(-defaultHistoryRange)
+	if v := query.Get("from"); len(v) > 0 {
+		q.from, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid["from"] = "must be an RFC 3339 timestamp"
+		} else if q.from.After(q.to) {
+			invalid["from"] = "must not be after to"
+		}
+	}
+	if v := query.Get("bucket"); len(v) > 0 {
+		q.bucket, err = time.ParseDuration(v)
+		if err != nil || q.bucket < minHistoryBucket {
+			invalid["bucket"] = "must be a duration of at least " + minHistoryBucket.String()
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+	return q, nil
+}
+
+// toHistoryPoints converts the recorded versions of a unit into the points where its state changed.
+// With a bucket, only the last state of each bucket is kept and it is reported at the start of the bucket.
+func toHistoryPoints(units []*prefeituradeps.DeOlhoNaFilaUnit, bucket time.Duration) []*historyPoint {
+	points := []*historyPoint{}
+	for _, raw := range units {
+		unit, _ := domain.FromDeOlhoNaFila(raw)
+		point := &historyPoint{
+			LastUpdatedAt: unit.LastUpdatedAt,
+			LineIndex:     unit.LineIndex,
+			LineStatus:    unit.LineStatus,
+			Vaccines:      unit.Vaccines,
+		}
+		if bucket > 0 {
+			point.LastUpdatedAt = point.LastUpdatedAt.Truncate(bucket)
+			if last := len(points) - 1; last >= 0 && points[last].LastUpdatedAt.Equal(point.LastUpdatedAt) {
+				points = points[:last]
+			}
+		}
+		if last := len(points) - 1; last >= 0 && points[last].sameState(point) {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func (h *httpHandler) unitHistory(w http.ResponseWriter, req *http.Request) {
+	query, invalid := parseHistoryQuery(mux.Vars(req)["id"], req.URL.Query(), time.Now())
+	if invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", invalid)
+		return
+	}
+
+	units, err := h.SnapshotStore.History(query.unitID, query.from, query.to)
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error reading history", err)
+		return
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err = json.NewEncoder(w).Encode(toHistoryPoints(units, query.bucket))
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
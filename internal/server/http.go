@@ -2,13 +2,22 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/breaker"
 	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
 	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	prefeituradeps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
 )
 
 const (
@@ -20,61 +29,298 @@ const (
 // Server represents the HTTP server
 type Server struct {
 	*mux.Router
+
+	handler *httpHandler
+}
+
+// CloseStreams ends the streaming responses in progress, such as GET /events, so that
+// http.Server.Shutdown doesn't wait for them. Streams opened afterwards end immediately.
+func (s *Server) CloseStreams() {
+	s.handler.closeStreams.Do(func() { close(s.handler.streamsClosed) })
+}
+
+// CircuitBreaker reports the state of the circuit breaker protecting the data source
+type CircuitBreaker interface {
+	Status() breaker.Status
 }
 
 type httpHandler struct {
 	DeOlhoNaFilaClient deps.DeOlhoNaFila
+	GeocodeStore       deps.GeocodeStore
+	CircuitBreaker     CircuitBreaker
+	SnapshotStore      deps.SnapshotStore
+	Heatmap            Heatmap
+	ChangeLog          ChangeLog
+	SubscriptionStore  deps.SubscriptionStore
+	WebhookAdminToken  string
+	WebhookPartners    map[string]string
+	Resolver           deps.Resolver
+	Metrics            *prometheus.Registry
+	Refresher          Refresher
+	ReadinessMaxAge    time.Duration
+	CORSOrigins        []string
+	Logger             *log.Logger
+
+	streamsClosed chan struct{}
+	closeStreams  sync.Once
+}
+
+// Option configures optional dependencies of the server
+type Option func(*httpHandler)
+
+// WithGeocodeStore sets the store with the coordinates added to units. Units are geocoded in the background
+// so units missing from the store are served without coordinates.
+func WithGeocodeStore(store deps.GeocodeStore) Option {
+	return func(h *httpHandler) {
+		h.GeocodeStore = store
+	}
+}
+
+// WithCircuitBreaker exposes the state of the circuit breaker on GET /admin/circuit-breaker
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(h *httpHandler) {
+		h.CircuitBreaker = cb
+	}
+}
+
+// WithSnapshotStore serves the history of each unit on GET /units/{id}/history
+func WithSnapshotStore(store deps.SnapshotStore) Option {
+	return func(h *httpHandler) {
+		h.SnapshotStore = store
+	}
+}
+
+// WithHeatmap serves the typical line index of units on GET /heatmap and GET /units/{id}/heatmap
+func WithHeatmap(heatmap Heatmap) Option {
+	return func(h *httpHandler) {
+		h.Heatmap = heatmap
+	}
+}
+
+// WithChangeLog serves the changes detected between snapshots on GET /changes and streams them on GET /events
+func WithChangeLog(log ChangeLog) Option {
+	return func(h *httpHandler) {
+		h.ChangeLog = log
+	}
+}
+
+// WithSubscriptionStore serves the webhook subscriptions API on /webhooks. Requests are refused
+// unless they carry one of the tokens given to WithWebhookTokens.
+func WithSubscriptionStore(store deps.SubscriptionStore) Option {
+	return func(h *httpHandler) {
+		h.SubscriptionStore = store
+	}
+}
+
+// WithWebhookTokens sets the bearer tokens accepted by the webhook subscriptions API. adminToken manages
+// every subscription while partners maps each partner token to the partner name, which only manages
+// the subscriptions it created.
+func WithWebhookTokens(adminToken string, partners map[string]string) Option {
+	return func(h *httpHandler) {
+		h.WebhookAdminToken = adminToken
+		h.WebhookPartners = partners
+	}
+}
+
+// WithResolver sets the resolver used to check that webhook URLs only point to public addresses
+func WithResolver(resolver deps.Resolver) Option {
+	return func(h *httpHandler) {
+		h.Resolver = resolver
+	}
+}
+
+// WithMetrics records HTTP metrics in registry and exposes every metric in it on GET /metrics
+func WithMetrics(registry *prometheus.Registry) Option {
+	return func(h *httpHandler) {
+		h.Metrics = registry
+	}
+}
+
+// WithReadiness reports on GET /readyz whether refresher fetched data and whether it's older than maxAge
+func WithReadiness(refresher Refresher, maxAge time.Duration) Option {
+	return func(h *httpHandler) {
+		h.Refresher = refresher
+		h.ReadinessMaxAge = maxAge
+	}
+}
+
+// WithCORS allows browsers on origins to call the API. AnyOrigin allows every origin
+func WithCORS(origins []string) Option {
+	return func(h *httpHandler) {
+		h.CORSOrigins = origins
+	}
+}
+
+// WithLogger logs the errors behind error responses to ll instead of the standard logger
+func WithLogger(ll *log.Logger) Option {
+	return func(h *httpHandler) {
+		h.Logger = ll
+	}
 }
 
 // NewHTTPServer creates a new server
-func NewHTTPServer(client deps.DeOlhoNaFila) *Server {
-	handler := &httpHandler{DeOlhoNaFilaClient: client}
+func NewHTTPServer(client deps.DeOlhoNaFila, opts ...Option) *Server {
+	handler := &httpHandler{DeOlhoNaFilaClient: client, Logger: log.Default(), Resolver: net.DefaultResolver, streamsClosed: make(chan struct{})}
+	for _, opt := range opts {
+		opt(handler)
+	}
 
 	r := mux.NewRouter()
+	r.Use(requestIDMiddleware)
+	// mux doesn't run middlewares for unmatched routes so these handlers are wrapped explicitly
+	r.NotFoundHandler = requestIDMiddleware(http.HandlerFunc(notFound))
+	r.MethodNotAllowedHandler = requestIDMiddleware(http.HandlerFunc(methodNotAllowed))
+	if len(handler.CORSOrigins) > 0 {
+		cors := corsMiddleware(handler.CORSOrigins)
+		r.Use(cors)
+		// Preflight requests use OPTIONS, which no route accepts
+		r.MethodNotAllowedHandler = cors(r.MethodNotAllowedHandler)
+	}
+	if handler.Metrics != nil {
+		httpMetrics := newHTTPMetrics(handler.Metrics)
+		r.Use(httpMetrics.middleware)
+		r.NotFoundHandler = httpMetrics.middleware(r.NotFoundHandler)
+		r.MethodNotAllowedHandler = httpMetrics.middleware(r.MethodNotAllowedHandler)
+		r.Handle("/metrics", promhttp.HandlerFor(handler.Metrics, promhttp.HandlerOpts{})).Methods(http.MethodGet)
+	}
+	r.HandleFunc("/healthz", handler.healthz).Methods(http.MethodGet)
+	if handler.Refresher != nil {
+		r.HandleFunc("/readyz", handler.readyz).Methods(http.MethodGet)
+	}
 	r.HandleFunc("/data.raw", handler.rawData).Methods(http.MethodPost)
 	r.HandleFunc("/data", handler.data).Methods(http.MethodGet)
+	r.HandleFunc("/data.csv", handler.csvData).Methods(http.MethodGet)
+	r.HandleFunc("/units/nearby", handler.nearbyUnits).Methods(http.MethodGet)
+	if handler.SnapshotStore != nil {
+		r.HandleFunc("/units/{id}/history", handler.unitHistory).Methods(http.MethodGet)
+	}
+	if handler.Heatmap != nil {
+		r.HandleFunc("/heatmap", handler.heatmaps).Methods(http.MethodGet)
+		r.HandleFunc("/units/{id}/heatmap", handler.unitHeatmap).Methods(http.MethodGet)
+	}
+	if handler.ChangeLog != nil {
+		r.HandleFunc("/changes", handler.changes).Methods(http.MethodGet)
+		r.HandleFunc("/events", handler.events).Methods(http.MethodGet)
+	}
+	if handler.SubscriptionStore != nil {
+		r.HandleFunc("/webhooks", handler.createWebhook).Methods(http.MethodPost)
+		r.HandleFunc("/webhooks", handler.listWebhooks).Methods(http.MethodGet)
+		r.HandleFunc("/webhooks/{id}", handler.deleteWebhook).Methods(http.MethodDelete)
+	}
+	if handler.CircuitBreaker != nil {
+		r.HandleFunc("/admin/circuit-breaker", handler.circuitBreakerStatus).Methods(http.MethodGet)
+	}
 
-	return &Server{r}
+	return &Server{Router: r, handler: handler}
 }
 
 func (h *httpHandler) rawData(w http.ResponseWriter, req *http.Request) {
 	req.ParseForm()
 	if len(req.PostForm) == 0 {
-		h.writeError(w, http.StatusBadRequest, "missing body", nil)
+		h.writeError(w, req, http.StatusBadRequest, ErrorCodeMissingBody, "missing body", nil)
 		return
 	}
 	val := req.PostForm.Get(mandatoryBodyFieldName)
 	if len(val) == 0 {
-		h.writeError(w, http.StatusBadRequest, "invalid body", nil)
+		h.writeError(w, req, http.StatusBadRequest, ErrorCodeInvalidBody, "invalid body", nil)
 		return
 	}
 
 	units, err := h.DeOlhoNaFilaClient.Fetch(req.Context())
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "error fetching data", err)
+		h.writeFetchError(w, req, err)
 		return
 	}
 
 	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
 	err = json.NewEncoder(w).Encode(units)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "error encoding data", err)
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
 		return
 	}
 }
 
-func (h *httpHandler) data(w http.ResponseWriter, _ *http.Request) {
-	// TODO: Implement
-	h.writeError(w, http.StatusNotImplemented, "not implemented yet", nil)
+func (h *httpHandler) data(w http.ResponseWriter, req *http.Request) {
+	format, ok := responseFormat(req)
+	if !ok {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", map[string]string{
+			"format": "must be json, geojson or csv",
+		})
+		return
+	}
+	h.serveUnits(w, req, format)
 }
 
-func (h *httpHandler) writeError(w http.ResponseWriter, statusCode int, baseMessage string, err error) {
-	w.WriteHeader(statusCode)
-	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+func (h *httpHandler) csvData(w http.ResponseWriter, req *http.Request) {
+	h.serveUnits(w, req, formatCSV)
+}
+
+func (h *httpHandler) serveUnits(w http.ResponseWriter, req *http.Request, format string) {
+	filters, invalid := parseUnitFilters(req.URL.Query())
+	if invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidFilter, "invalid filter", invalid)
+		return
+	}
+	// bom only affects CSV responses
+	bom, err := parseOptionalBool(req.URL.Query(), "bom")
+	if err != nil && format == formatCSV {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", map[string]string{
+			"bom": "must be true or false",
+		})
+		return
+	}
 
-	errorMsg := baseMessage
+	units, err := h.DeOlhoNaFilaClient.Fetch(req.Context())
 	if err != nil {
-		errorMsg = fmt.Sprintf("%s: %s", baseMessage, err)
+		h.writeFetchError(w, req, err)
+		return
+	}
+
+	switch format {
+	case formatCSV:
+		// Rows are streamed so errors after this point can't change the response status
+		h.writeCSV(req.Context(), w, filters.apply(units), bom)
+		return
+	case formatGeoJSON:
+		w.Header().Add(prefeitura.ContentTypeHeader, GeoJSONContentType)
+		err = json.NewEncoder(w).Encode(toFeatureCollection(h.toDomain(filters.apply(units))))
+	default:
+		w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+		err = json.NewEncoder(w).Encode(h.toDomain(filters.apply(units)))
+	}
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
+
+// toDomain converts units into the domain model and adds their coordinates when known.
+// Units with validation problems are kept with the fields that could be parsed so that a
+// single malformed field doesn't hide a vaccination post from users.
+func (h *httpHandler) toDomain(units []*prefeituradeps.DeOlhoNaFilaUnit) []*domain.Unit {
+	results := make([]*domain.Unit, 0, len(units))
+	for _, raw := range units {
+		unit, _ := domain.FromDeOlhoNaFila(raw)
+		unit.Coordinates = h.coordinates(raw)
+		results = append(results, unit)
 	}
-	w.Write([]byte(fmt.Sprintf("{\"error\":\"%s\"}", errorMsg)))
-}
\ No newline at end of file
+	return results
+}
+
+// coordinates returns the stored coordinates of the unit or nil when they aren't known
+func (h *httpHandler) coordinates(unit *prefeituradeps.DeOlhoNaFilaUnit) *geo.Coordinates {
+	if h.GeocodeStore == nil {
+		return nil
+	}
+	coords, _ := h.GeocodeStore.Lookup(unit.ID(), unit.Address)
+	return coords
+}
+
+func (h *httpHandler) circuitBreakerStatus(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err := json.NewEncoder(w).Encode(h.CircuitBreaker.Status())
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
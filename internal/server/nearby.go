@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+)
+
+const (
+	defaultNearbyRadiusKm = 5.0
+	maxNearbyRadiusKm     = 50.0
+	defaultNearbyLimit    = 10
+	maxNearbyLimit        = 100
+)
+
+// nearbyUnit is a unit along with its distance to the searched point
+type nearbyUnit struct {
+	*domain.Unit
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// nearbyQuery holds the location parameters of GET /units/nearby
+type nearbyQuery struct {
+	origin   *geo.Coordinates
+	radiusKm float64
+	limit    int
+}
+
+func parseNearbyQuery(query url.Values) (*nearbyQuery, map[string]string) {
+	invalid := map[string]string{}
+	q := &nearbyQuery{origin: &geo.Coordinates{}, radiusKm: defaultNearbyRadiusKm, limit: defaultNearbyLimit}
+
+	lat, err := parseFiniteFloat(query.Get("lat"))
+	if err != nil || lat < -90 || lat > 90 {
+		invalid["lat"] = "must be a latitude between -90 and 90"
+	}
+	lng, err := parseFiniteFloat(query.Get("lng"))
+	if err != nil || lng < -180 || lng > 180 {
+		invalid["lng"] = "must be a longitude between -180 and 180"
+	}
+	q.origin.Latitude, q.origin.Longitude = lat, lng
+
+	if v := query.Get("radius_km"); len(v) > 0 {
+		q.radiusKm, err = parseFiniteFloat(v)
+		if err != nil || q.radiusKm <= 0 || q.radiusKm > maxNearbyRadiusKm {
+			invalid["radius_km"] = "must be a number greater than 0 and up to " + strconv.FormatFloat(maxNearbyRadiusKm, 'f', -1, 64)
+		}
+	}
+	if v := query.Get("limit"); len(v) > 0 {
+		q.limit, err = strconv.Atoi(v)
+		if err != nil || q.limit < 1 || q.limit > maxNearbyLimit {
+			invalid["limit"] = "must be an integer between 1 and " + strconv.Itoa(maxNearbyLimit)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+	return q, nil
+}
+
+// parseFiniteFloat parses v rejecting NaN and infinities, which ParseFloat accepts and range checks let through
+func parseFiniteFloat(v string) (float64, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	return f, nil
+}
+
+func (h *httpHandler) nearbyUnits(w http.ResponseWriter, req *http.Request) {
+	query, invalid := parseNearbyQuery(req.URL.Query())
+	if invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidParameter, "invalid parameter", invalid)
+		return
+	}
+	filters, invalid := parseUnitFilters(req.URL.Query())
+	if invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidFilter, "invalid filter", invalid)
+		return
+	}
+
+	units, err := h.DeOlhoNaFilaClient.Fetch(req.Context())
+	if err != nil {
+		h.writeFetchError(w, req, err)
+		return
+	}
+
+	results := []*nearbyUnit{}
+	for _, unit := range h.toDomain(filters.apply(units)) {
+		if unit.Coordinates == nil {
+			continue
+		}
+		distance := query.origin.DistanceKm(unit.Coordinates)
+		if distance > query.radiusKm {
+			continue
+		}
+		results = append(results, &nearbyUnit{Unit: unit, DistanceKm: distance})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].DistanceKm < results[j].DistanceKm })
+	if len(results) > query.limit {
+		results = results[:query.limit]
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
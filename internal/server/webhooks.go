@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/webhooks"
+)
+
+// webhookCaller identifies who calls the webhook subscriptions API
+type webhookCaller struct {
+	// owner is the partner name, empty for the admin
+	owner string
+	admin bool
+}
+
+// canManage reports whether the caller can see and delete subscription
+func (c *webhookCaller) canManage(subscription *webhook.Subscription) bool {
+	return c.admin || subscription.Owner == c.owner
+}
+
+// webhookCaller returns the caller matching the bearer token of req or nil when the token is unknown
+func (h *httpHandler) webhookCaller(req *http.Request) *webhookCaller {
+	const prefix = "Bearer "
+	header := req.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil
+	}
+	token := []byte(header[len(prefix):])
+	if len(h.WebhookAdminToken) > 0 && subtle.ConstantTimeCompare(token, []byte(h.WebhookAdminToken)) == 1 {
+		return &webhookCaller{admin: true}
+	}
+	for partnerToken, partner := range h.WebhookPartners {
+		if subtle.ConstantTimeCompare(token, []byte(partnerToken)) == 1 {
+			return &webhookCaller{owner: partner}
+		}
+	}
+	return nil
+}
+
+// validateSubscription maps each invalid field of a new subscription to the reason it was rejected
+func (h *httpHandler) validateSubscription(ctx context.Context, subscription *webhook.Subscription) map[string]string {
+	invalid := map[string]string{}
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		invalid["url"] = "must be an absolute http or https URL"
+	} else if err := webhooks.CheckHost(ctx, h.Resolver, u.Hostname()); errors.Is(err, httpclient.ErrNonPublicAddress) {
+		invalid["url"] = "must not point to loopback, private or link-local addresses"
+	} else if err != nil {
+		invalid["url"] = "must have a host that can be resolved"
+	}
+	for field, ids := range map[string][]int{
+		"region_ids":       subscription.RegionIDs,
+		"neighborhood_ids": subscription.NeighborhoodIDs,
+		"unit_ids":         subscription.UnitIDs,
+	} {
+		for _, id := range ids {
+			if id < 1 {
+				invalid[field] = "must be a list of positive integers"
+				break
+			}
+		}
+	}
+	for _, vaccine := range subscription.Vaccines {
+		if !isKnownVaccine(vaccine) {
+			invalid["vaccines"] = "must be a list of coronavac, astrazeneca or pfizer"
+			break
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+func (h *httpHandler) createWebhook(w http.ResponseWriter, req *http.Request) {
+	caller := h.webhookCaller(req)
+	if caller == nil {
+		unauthorized(w, req)
+		return
+	}
+	subscription := &webhook.Subscription{}
+	if err := json.NewDecoder(req.Body).Decode(subscription); err != nil {
+		h.writeError(w, req, http.StatusBadRequest, ErrorCodeInvalidBody, "invalid body", nil)
+		return
+	}
+	subscription.Owner = caller.owner
+	if invalid := h.validateSubscription(req.Context(), subscription); invalid != nil {
+		writeErrorDetails(w, req, http.StatusBadRequest, ErrorCodeInvalidBody, "invalid body", invalid)
+		return
+	}
+	if err := webhooks.Register(h.SubscriptionStore, subscription); err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error saving subscription", err)
+		return
+	}
+
+	// The secret is only shown once, when the subscription is created
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	w.Header().Set("Location", "/webhooks/"+subscription.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (h *httpHandler) listWebhooks(w http.ResponseWriter, req *http.Request) {
+	caller := h.webhookCaller(req)
+	if caller == nil {
+		unauthorized(w, req)
+		return
+	}
+	subscriptions, err := h.SubscriptionStore.List()
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error listing subscriptions", err)
+		return
+	}
+	visible := []*webhook.Subscription{}
+	for _, subscription := range subscriptions {
+		if caller.canManage(subscription) {
+			subscription.Secret = ""
+			visible = append(visible, subscription)
+		}
+	}
+
+	w.Header().Add(prefeitura.ContentTypeHeader, JSONContentType)
+	err = json.NewEncoder(w).Encode(visible)
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error encoding data", err)
+		return
+	}
+}
+
+func (h *httpHandler) deleteWebhook(w http.ResponseWriter, req *http.Request) {
+	caller := h.webhookCaller(req)
+	if caller == nil {
+		unauthorized(w, req)
+		return
+	}
+	id := mux.Vars(req)["id"]
+	if !caller.admin {
+		subscriptions, err := h.SubscriptionStore.List()
+		if err != nil {
+			h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error listing subscriptions", err)
+			return
+		}
+		// Subscriptions of other partners are reported as missing so their ids aren't disclosed
+		if !ownsSubscription(caller, subscriptions, id) {
+			notFound(w, req)
+			return
+		}
+	}
+	deleted, err := h.SubscriptionStore.Delete(id)
+	if err != nil {
+		h.writeError(w, req, http.StatusInternalServerError, ErrorCodeInternal, "error deleting subscription", err)
+		return
+	}
+	if !deleted {
+		notFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ownsSubscription(caller *webhookCaller, subscriptions []*webhook.Subscription, id string) bool {
+	for _, subscription := range subscriptions {
+		if subscription.ID == id {
+			return caller.canManage(subscription)
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+)
+
+// GeocodeFileStore keeps the coordinates of each unit in memory and persists them to a JSON file on Flush
+type GeocodeFileStore struct {
+	path string
+
+	mutex   sync.RWMutex
+	entries map[string]*geocodeEntry
+	dirty   bool
+}
+
+type geocodeEntry struct {
+	Address     string           `json:"address"`
+	Coordinates *geo.Coordinates `json:"coordinates"`
+}
+
+// NewGeocodeFileStore creates a store backed by the file at path, loading any entries already in it
+func NewGeocodeFileStore(path string) (*GeocodeFileStore, error) {
+	s := &GeocodeFileStore{path: path, entries: map[string]*geocodeEntry{}}
+
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(content, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the coordinates of the unit if they were found using the same address
+func (s *GeocodeFileStore) Lookup(unitID int, address string) (*geo.Coordinates, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, ok := s.entries[strconv.Itoa(unitID)]
+	if !ok || entry.Address != NormalizeAddress(address) {
+		return nil, false
+	}
+	return entry.Coordinates, true
+}
+
+// Save stores the coordinates of the unit in memory. Nil coordinates record that the address wasn't found.
+func (s *GeocodeFileStore) Save(unitID int, address string, coords *geo.Coordinates) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[strconv.Itoa(unitID)] = &geocodeEntry{Address: NormalizeAddress(address), Coordinates: coords}
+	s.dirty = true
+	return nil
+}
+
+// Flush writes the whole store to disk if anything was saved since the last flush
+func (s *GeocodeFileStore) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	content, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(s.path, content); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// NormalizeAddress reduces formatting differences in addresses so that cosmetic changes don't
+// trigger a new geocoding
+func NormalizeAddress(address string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(address), " "))
+}
+
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
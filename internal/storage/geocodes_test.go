@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/geo"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.GeocodeStore = &storage.GeocodeFileStore{}
+
+func TestGeocodeFileStore_LookupMissesUnknownUnit(t *testing.T) {
+	store, err := storage.NewGeocodeFileStore(filepath.Join(t.TempDir(), "geocodes.json"))
+	require.NoError(t, err, "expected error to match")
+
+	_, found := store.Lookup(1, "Rua Vergueiro, 235")
+	assert.False(t, found, "expected lookup to miss")
+}
+
+func TestGeocodeFileStore_LookupHitsIgnoringCosmeticAddressChanges(t *testing.T) {
+	store, err := storage.NewGeocodeFileStore(filepath.Join(t.TempDir(), "geocodes.json"))
+	require.NoError(t, err, "expected error to match")
+
+	require.NoError(t, store.Save(1, "Rua Vergueiro, 235", &geo.Coordinates{Latitude: -23.5, Longitude: -46.6}))
+	coords, found := store.Lookup(1, "  RUA VERGUEIRO,   235 ")
+	require.True(t, found, "expected lookup to hit")
+	assert.Equal(t, &geo.Coordinates{Latitude: -23.5, Longitude: -46.6}, coords, "expected coordinates to match")
+}
+
+func TestGeocodeFileStore_LookupMissesWhenAddressChanges(t *testing.T) {
+	store, err := storage.NewGeocodeFileStore(filepath.Join(t.TempDir(), "geocodes.json"))
+	require.NoError(t, err, "expected error to match")
+
+	require.NoError(t, store.Save(1, "Rua Vergueiro, 235", &geo.Coordinates{Latitude: -23.5, Longitude: -46.6}))
+	_, found := store.Lookup(1, "Rua Vergueiro, 1000")
+	assert.False(t, found, "expected lookup to miss")
+}
+
+func TestGeocodeFileStore_SurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocodes.json")
+	store, err := storage.NewGeocodeFileStore(path)
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Save(7, "Rua Humaitá, 520", &geo.Coordinates{Latitude: -23.56, Longitude: -46.64}))
+	require.NoError(t, store.Save(8, "Lugar nenhum", nil))
+	require.NoError(t, store.Flush(), "expected error to match")
+
+	reopened, err := storage.NewGeocodeFileStore(path)
+	require.NoError(t, err, "expected error to match")
+	coords, found := reopened.Lookup(7, "Rua Humaitá, 520")
+	require.True(t, found, "expected lookup to hit")
+	assert.Equal(t, &geo.Coordinates{Latitude: -23.56, Longitude: -46.64}, coords, "expected coordinates to match")
+	coords, found = reopened.Lookup(8, "Lugar nenhum")
+	assert.True(t, found, "expected addresses that weren't found to be remembered")
+	assert.Nil(t, coords, "expected no coordinates")
+}
+
+func TestGeocodeFileStore_OnlyWritesOnFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocodes.json")
+	store, err := storage.NewGeocodeFileStore(path)
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Save(7, "Rua Humaitá, 520", &geo.Coordinates{Latitude: -23.56, Longitude: -46.64}))
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "expected nothing to be written before flushing")
+	require.NoError(t, store.Flush(), "expected error to match")
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "expected file to be written on flush")
+}
+
+func TestGeocodeFileStore_ErrorsOnCorruptedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocodes.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{not json"), 0600))
+
+	_, err := storage.NewGeocodeFileStore(path)
+	require.Error(t, err, "expected error to match")
+}
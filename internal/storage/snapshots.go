@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+)
+
+const (
+	openTimeout = time.Second
+	// pruneInterval is how often, in fetch time, records older than the retention are removed
+	pruneInterval = time.Hour
+)
+
+var (
+	unitsBucket = []byte("units")
+)
+
+// BoltSnapshotStore keeps the history of every unit in a BoltDB file.
+// Each unit has its own bucket keyed by its data_hora so repeated fetches of the same update are stored once.
+type BoltSnapshotStore struct {
+	db        *bolt.DB
+	retention time.Duration
+	ll        *log.Logger
+
+	mutex    sync.Mutex
+	prunedAt time.Time
+}
+
+// record is a stored version of a unit along with when it was first fetched
+type record struct {
+	*prefeitura.DeOlhoNaFilaUnit
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// NewBoltSnapshotStore opens or creates the database at path. Records last updated more than
+// retention ago are removed as new snapshots are saved. A retention of zero keeps everything.
+func NewBoltSnapshotStore(path string, retention time.Duration, ll *log.Logger) (*BoltSnapshotStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(unitsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltSnapshotStore{db: db, retention: retention, ll: ll}, nil
+}
+
+// Close releases the database file
+func (s *BoltSnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+// Save records the units not recorded yet along with fetchedAt. Units with an unparsable data_hora are
+// skipped since they can't be placed in the history. Records older than the retention are pruned at
+// most once per pruneInterval.
+func (s *BoltSnapshotStore) Save(fetchedAt time.Time, units []*prefeitura.DeOlhoNaFilaUnit) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	saved := []*prefeitura.DeOlhoNaFilaUnit{}
+	skipped := []int{}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(unitsBucket)
+		for _, unit := range units {
+			updatedAt := unit.LastUpdatedAt()
+			// LastUpdatedAt returns the epoch when data_hora can't be parsed
+			if updatedAt.Equal(time.Unix(0, 0)) {
+				skipped = append(skipped, unit.ID())
+				continue
+			}
+			bucket, err := root.CreateBucketIfNotExists(intKey(int64(unit.ID())))
+			if err != nil {
+				return err
+			}
+			key := timeKey(updatedAt)
+			if bucket.Get(key) != nil {
+				continue
+			}
+			value, err := json.Marshal(&record{DeOlhoNaFilaUnit: unit, FetchedAt: fetchedAt})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+			saved = append(saved, unit)
+		}
+		if s.shouldPrune(fetchedAt) {
+			return prune(root, fetchedAt.Add(-s.retention))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		s.ll.Printf("skipped units %v from history: unparsable data_hora", skipped)
+	}
+	return saved, nil
+}
+
+// History returns the recorded versions of a unit last updated between from and to, oldest first
+func (s *BoltSnapshotStore) History(unitID int, from, to time.Time) ([]*prefeitura.DeOlhoNaFilaUnit, error) {
+	results := []*prefeitura.DeOlhoNaFilaUnit{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(unitsBucket).Bucket(intKey(int64(unitID)))
+		if bucket == nil {
+			return nil
+		}
+		end := timeKey(to)
+		c := bucket.Cursor()
+		for k, v := c.Seek(timeKey(from)); k != nil && string(k) <= string(end); k, v = c.Next() {
+			r, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			results = append(results, r.DeOlhoNaFilaUnit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Walk calls fn with every recorded version last updated since from, unit by unit, oldest first
+func (s *BoltSnapshotStore) Walk(from time.Time, fn func(*prefeitura.DeOlhoNaFilaUnit) error) error {
+	start := timeKey(from)
+	return s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(unitsBucket)
+		return root.ForEach(func(unitKey, _ []byte) error {
+			c := root.Bucket(unitKey).Cursor()
+			for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+				r, err := decodeRecord(v)
+				if err != nil {
+					return err
+				}
+				if err := fn(r.DeOlhoNaFilaUnit); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// shouldPrune reports whether records must be pruned while saving a snapshot fetched at fetchedAt
+func (s *BoltSnapshotStore) shouldPrune(fetchedAt time.Time) bool {
+	if s.retention <= 0 {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.prunedAt.IsZero() && fetchedAt.Sub(s.prunedAt) < pruneInterval {
+		return false
+	}
+	s.prunedAt = fetchedAt
+	return true
+}
+
+// decodeRecord reads a stored record. Records saved before fetch times were kept have a zero FetchedAt.
+func decodeRecord(value []byte) (*record, error) {
+	r := &record{DeOlhoNaFilaUnit: &prefeitura.DeOlhoNaFilaUnit{}}
+	if err := json.Unmarshal(value, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// prune removes every record last updated before the given time
+func prune(root *bolt.Bucket, before time.Time) error {
+	limit := timeKey(before)
+	return root.ForEach(func(unitKey, _ []byte) error {
+		c := root.Bucket(unitKey).Cursor()
+		for k, _ := c.First(); k != nil && string(k) < string(limit); k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func intKey(v int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(v))
+	return key
+}
+
+// timeKey encodes t so that keys sort chronologically for any time after the Unix epoch
+func timeKey(t time.Time) []byte {
+	nanos := t.UnixNano()
+	if nanos < 0 {
+		nanos = 0
+	}
+	return intKey(nanos)
+}
@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.SnapshotStore = &storage.BoltSnapshotStore{}
+
+var discardLogger = log.New(ioutil.Discard, "", 0)
+
+func newSnapshotStore(t *testing.T, retention time.Duration) *storage.BoltSnapshotStore {
+	store, err := storage.NewBoltSnapshotStore(filepath.Join(t.TempDir(), "history.db"), retention, discardLogger)
+	require.NoError(t, err, "expected error to match")
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func historyUnit(id, updatedAt, lineIndex string) *prefeitura.DeOlhoNaFilaUnit {
+	return &prefeitura.DeOlhoNaFilaUnit{IDStr: id, LastUpdatedAtStr: updatedAt, LineIndexStr: lineIndex}
+}
+
+func TestBoltSnapshotStore_SaveIgnoresUnitsAlreadyRecorded(t *testing.T) {
+	store := newSnapshotStore(t, 0)
+	fetchedAt := time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	saved, err := store.Save(fetchedAt, []*prefeitura.DeOlhoNaFilaUnit{
+		historyUnit("1", "2021-07-01 09:00:00.000", "1"),
+		historyUnit("2", "2021-07-01 09:00:00.000", "2"),
+	})
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, saved, 2, "expected every unit to be recorded")
+
+	saved, err = store.Save(fetchedAt.Add(time.Minute), []*prefeitura.DeOlhoNaFilaUnit{
+		historyUnit("1", "2021-07-01 09:00:00.000", "1"),
+		historyUnit("2", "2021-07-01 09:30:00.000", "3"),
+	})
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, saved, 1, "expected only the updated unit to be recorded")
+	assert.Equal(t, "2", saved[0].IDStr, "expected id to match")
+}
+
+func TestBoltSnapshotStore_HistoryReturnsUnitVersionsInRange(t *testing.T) {
+	store := newSnapshotStore(t, 0)
+	fetchedAt := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, u := range []*prefeitura.DeOlhoNaFilaUnit{
+		historyUnit("1", "2021-07-01 11:00:00.000", "3"),
+		historyUnit("1", "2021-07-01 09:00:00.000", "1"),
+		historyUnit("1", "2021-07-01 10:00:00.000", "2"),
+		historyUnit("2", "2021-07-01 10:00:00.000", "5"),
+	} {
+		_, err := store.Save(fetchedAt, []*prefeitura.DeOlhoNaFilaUnit{u})
+		require.NoError(t, err, "expected error to match")
+	}
+
+	history, err := store.History(1, parseTime(t, "2021-07-01 09:30:00.000"), parseTime(t, "2021-07-01 11:00:00.000"))
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, history, 2, "expected length to match")
+	assert.Equal(t, "2", history[0].LineIndexStr, "expected oldest version first")
+	assert.Equal(t, "3", history[1].LineIndexStr, "expected newest version last")
+}
+
+func TestBoltSnapshotStore_HistoryIsEmptyForUnknownUnit(t *testing.T) {
+	store := newSnapshotStore(t, 0)
+
+	history, err := store.History(42, time.Time{}, time.Now())
+	require.NoError(t, err, "expected error to match")
+	assert.Empty(t, history, "expected no history")
+}
+
+func TestBoltSnapshotStore_SavePrunesRecordsOlderThanRetention(t *testing.T) {
+	store := newSnapshotStore(t, 24*time.Hour)
+
+	_, err := store.Save(parseTime(t, "2021-07-01 10:00:00.000"), []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", "2021-07-01 09:00:00.000", "1")})
+	require.NoError(t, err, "expected error to match")
+	_, err = store.Save(parseTime(t, "2021-07-03 10:00:00.000"), []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", "2021-07-03 09:00:00.000", "2")})
+	require.NoError(t, err, "expected error to match")
+
+	history, err := store.History(1, time.Time{}, parseTime(t, "2021-07-04 00:00:00.000"))
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, history, 1, "expected old record to be pruned")
+	assert.Equal(t, "2", history[0].LineIndexStr, "expected recent record to be kept")
+}
+
+func TestBoltSnapshotStore_SavePrunesAtMostOncePerHourOfFetches(t *testing.T) {
+	store := newSnapshotStore(t, time.Hour)
+	save := func(fetchedAt, updatedAt, lineIndex string) {
+		_, err := store.Save(parseTime(t, fetchedAt), []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", updatedAt, lineIndex)})
+		require.NoError(t, err, "expected error to match")
+	}
+	history := func() []*prefeitura.DeOlhoNaFilaUnit {
+		history, err := store.History(1, time.Time{}, parseTime(t, "2021-07-02 00:00:00.000"))
+		require.NoError(t, err, "expected error to match")
+		return history
+	}
+
+	save("2021-07-01 10:00:00.000", "2021-07-01 09:30:00.000", "1")
+	save("2021-07-01 10:40:00.000", "2021-07-01 10:35:00.000", "2")
+	assert.Len(t, history(), 2, "expected no pruning within the interval")
+
+	save("2021-07-01 11:00:00.000", "2021-07-01 10:55:00.000", "3")
+	versions := history()
+	require.Len(t, versions, 2, "expected old record to be pruned after the interval")
+	assert.Equal(t, "2", versions[0].LineIndexStr, "expected recent records to be kept")
+}
+
+func TestBoltSnapshotStore_SaveSkipsUnitsWithUnparsableUpdateTime(t *testing.T) {
+	logs := &bytes.Buffer{}
+	store, err := storage.NewBoltSnapshotStore(filepath.Join(t.TempDir(), "history.db"), time.Hour, log.New(logs, "", 0))
+	require.NoError(t, err, "expected error to match")
+	defer store.Close()
+
+	saved, err := store.Save(parseTime(t, "2021-07-01 10:00:00.000"), []*prefeitura.DeOlhoNaFilaUnit{
+		historyUnit("1", "yesterday", "1"),
+		historyUnit("2", "2021-07-01 09:30:00.000", "2"),
+	})
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, saved, 1, "expected only valid units to be recorded")
+	assert.Equal(t, "2", saved[0].IDStr, "expected id to match")
+	assert.Contains(t, logs.String(), "skipped units [1]", "expected skipped units to be logged")
+
+	history, err := store.History(1, time.Time{}, time.Now())
+	require.NoError(t, err, "expected error to match")
+	assert.Empty(t, history, "expected no epoch record")
+}
+
+func TestBoltSnapshotStore_SaveRecordsFetchTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := storage.NewBoltSnapshotStore(path, 0, discardLogger)
+	require.NoError(t, err, "expected error to match")
+	fetchedAt := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	_, err = store.Save(fetchedAt, []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", "2021-07-01 09:00:00.000", "1")})
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Close(), "expected error to match")
+
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err, "expected error to match")
+	defer db.Close()
+	stored := map[string]interface{}{}
+	err = db.View(func(tx *bolt.Tx) error {
+		_, v := tx.Bucket([]byte("units")).Bucket([]byte{0, 0, 0, 0, 0, 0, 0, 1}).Cursor().First()
+		return json.Unmarshal(v, &stored)
+	})
+	require.NoError(t, err, "expected error to match")
+	assert.Equal(t, "2021-07-01T12:00:00Z", stored["fetched_at"], "expected fetch time to be stored")
+	assert.Equal(t, "1", stored["id_tb_unidades"], "expected unit fields to be stored")
+}
+
+func TestBoltSnapshotStore_KeepsHistoryAcrossReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := storage.NewBoltSnapshotStore(path, 0, discardLogger)
+	require.NoError(t, err, "expected error to match")
+	_, err = store.Save(time.Now(), []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", "2021-07-01 09:00:00.000", "1")})
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Close(), "expected error to match")
+
+	store, err = storage.NewBoltSnapshotStore(path, 0, discardLogger)
+	require.NoError(t, err, "expected error to match")
+	defer store.Close()
+	history, err := store.History(1, time.Time{}, time.Now())
+	require.NoError(t, err, "expected error to match")
+	assert.Len(t, history, 1, "expected history to be persisted")
+}
+
+// parseTime reads value as a data_hora field
+func parseTime(t *testing.T, value string) time.Time {
+	parsed := historyUnit("0", value, "").LastUpdatedAt()
+	require.False(t, parsed.Equal(time.Unix(0, 0)), "expected valid time")
+	return parsed
+}
+
+func TestBoltSnapshotStore_WalkVisitsVersionsSinceTime(t *testing.T) {
+	store := newSnapshotStore(t, 0)
+	_, err := store.Save(time.Now(), []*prefeitura.DeOlhoNaFilaUnit{
+		historyUnit("1", "2021-07-01 09:00:00.000", "1"),
+		historyUnit("2", "2021-07-01 10:00:00.000", "2"),
+	})
+	require.NoError(t, err, "expected error to match")
+	_, err = store.Save(time.Now(), []*prefeitura.DeOlhoNaFilaUnit{historyUnit("1", "2021-07-01 11:00:00.000", "3")})
+	require.NoError(t, err, "expected error to match")
+
+	visited := []string{}
+	err = store.Walk(parseTime(t, "2021-07-01 09:30:00.000"), func(u *prefeitura.DeOlhoNaFilaUnit) error {
+		visited = append(visited, u.IDStr+":"+u.LineIndexStr)
+		return nil
+	})
+	require.NoError(t, err, "expected error to match")
+	assert.ElementsMatch(t, []string{"1:3", "2:2"}, visited, "expected visited versions to match")
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+)
+
+// SubscriptionFileStore keeps webhook subscriptions in memory and persists them to a JSON file
+type SubscriptionFileStore struct {
+	path string
+
+	mutex         sync.RWMutex
+	subscriptions []*webhook.Subscription
+}
+
+// NewSubscriptionFileStore creates a store backed by the file at path, loading any subscriptions already in it
+func NewSubscriptionFileStore(path string) (*SubscriptionFileStore, error) {
+	s := &SubscriptionFileStore{path: path, subscriptions: []*webhook.Subscription{}}
+
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(content, &s.subscriptions); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns a copy of every subscription ordered by creation
+func (s *SubscriptionFileStore) List() ([]*webhook.Subscription, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := make([]*webhook.Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		copied := *subscription
+		results = append(results, &copied)
+	}
+	return results, nil
+}
+
+// Save creates or replaces the subscription with the same ID and persists the whole store to disk
+func (s *SubscriptionFileStore) Save(subscription *webhook.Subscription) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	copied := *subscription
+	for i, existing := range s.subscriptions {
+		if existing.ID == subscription.ID {
+			s.subscriptions[i] = &copied
+			return s.persist()
+		}
+	}
+	s.subscriptions = append(s.subscriptions, &copied)
+	return s.persist()
+}
+
+// Delete removes a subscription and persists the whole store to disk when it existed
+func (s *SubscriptionFileStore) Delete(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, existing := range s.subscriptions {
+		if existing.ID == id {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return true, s.persist()
+		}
+	}
+	return false, nil
+}
+
+func (s *SubscriptionFileStore) persist() error {
+	content, err := json.Marshal(s.subscriptions)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, content)
+}
@@ -0,0 +1,67 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ deps.SubscriptionStore = &storage.SubscriptionFileStore{}
+
+func TestSubscriptionFileStore_ListIsEmptyWithoutFile(t *testing.T) {
+	store, err := storage.NewSubscriptionFileStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err, "expected error to match")
+
+	subscriptions, err := store.List()
+	require.NoError(t, err, "expected error to match")
+	assert.Empty(t, subscriptions, "expected no subscriptions")
+}
+
+func TestSubscriptionFileStore_SaveReplacesSubscriptionWithSameID(t *testing.T) {
+	store, err := storage.NewSubscriptionFileStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err, "expected error to match")
+
+	require.NoError(t, store.Save(&webhook.Subscription{ID: "a", URL: "http://example.com/1"}))
+	require.NoError(t, store.Save(&webhook.Subscription{ID: "b", URL: "http://example.com/2"}))
+	require.NoError(t, store.Save(&webhook.Subscription{ID: "a", URL: "http://example.com/3"}))
+
+	subscriptions, err := store.List()
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, subscriptions, 2, "expected length to match")
+	assert.Equal(t, "http://example.com/3", subscriptions[0].URL, "expected subscription to be replaced in place")
+	assert.Equal(t, "b", subscriptions[1].ID, "expected id to match")
+}
+
+func TestSubscriptionFileStore_DeleteReportsWhetherSubscriptionExisted(t *testing.T) {
+	store, err := storage.NewSubscriptionFileStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Save(&webhook.Subscription{ID: "a"}))
+
+	deleted, err := store.Delete("a")
+	require.NoError(t, err, "expected error to match")
+	assert.True(t, deleted, "expected subscription to be deleted")
+	deleted, err = store.Delete("a")
+	require.NoError(t, err, "expected error to match")
+	assert.False(t, deleted, "expected missing subscription not to be deleted")
+}
+
+func TestSubscriptionFileStore_PersistsAcrossInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	store, err := storage.NewSubscriptionFileStore(path)
+	require.NoError(t, err, "expected error to match")
+	require.NoError(t, store.Save(&webhook.Subscription{ID: "a", Secret: "s3cr3t", Vaccines: []domain.Vaccine{domain.VaccinePfizer}}))
+
+	reloaded, err := storage.NewSubscriptionFileStore(path)
+	require.NoError(t, err, "expected error to match")
+	subscriptions, err := reloaded.List()
+	require.NoError(t, err, "expected error to match")
+	require.Len(t, subscriptions, 1, "expected length to match")
+	assert.Equal(t, "s3cr3t", subscriptions[0].Secret, "expected secret to be persisted")
+	assert.Equal(t, []domain.Vaccine{domain.VaccinePfizer}, subscriptions[0].Vaccines, "expected vaccines to match")
+}
@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+)
+
+const (
+	// EventHeader carries the type of the event delivered
+	EventHeader = "X-Onde2aDose-Event"
+	// DeliveryHeader carries the id of the event delivered so receivers can ignore retried duplicates
+	DeliveryHeader = "X-Onde2aDose-Delivery"
+
+	jsonContentType         = "application/json; charset=UTF-8"
+	maxConcurrentDeliveries = 8
+)
+
+// RetryPolicy controls how failed deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy retries for about half a minute before giving up on a delivery
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+// EventSource provides the changes to deliver
+type EventSource interface {
+	Subscribe(afterID int64) ([]*changes.Event, <-chan *changes.Event, func())
+}
+
+// Register completes a new subscription with an id, a secret when none was given and its creation time
+// and saves it in store
+func Register(store deps.SubscriptionStore, subscription *webhook.Subscription) error {
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	subscription.ID = id
+	if len(subscription.Secret) == 0 {
+		if subscription.Secret, err = randomHex(32); err != nil {
+			return err
+		}
+	}
+	subscription.CreatedAt = time.Now()
+	return store.Save(subscription)
+}
+
+// CheckHost resolves host and fails with httpclient.ErrNonPublicAddress when any of its addresses isn't
+// public, so subscriptions can't make this server call internal services
+func CheckHost(ctx context.Context, resolver deps.Resolver, host string) error {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if !httpclient.IsPublic(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", httpclient.ErrNonPublicAddress, host, ip)
+		}
+	}
+	return nil
+}
+
+// Dispatcher posts every change to the subscriptions whose filters match it
+type Dispatcher struct {
+	store  deps.SubscriptionStore
+	client deps.HTTPClient
+	ll     *log.Logger
+	// Retry is used for deliveries started after it's changed
+	Retry RetryPolicy
+
+	deliveries sync.WaitGroup
+	slots      chan struct{}
+}
+
+// NewDispatcher creates a dispatcher delivering to the subscriptions in store with client, which should
+// refuse non public addresses as the clients created by httpclient.NewPublicOnly do
+func NewDispatcher(store deps.SubscriptionStore, client deps.HTTPClient, ll *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: client,
+		ll:     ll,
+		Retry:  DefaultRetryPolicy,
+		slots:  make(chan struct{}, maxConcurrentDeliveries),
+	}
+}
+
+// Run delivers the events of source until ctx is done and then waits for pending deliveries to stop
+func (d *Dispatcher) Run(ctx context.Context, source EventSource) {
+	defer d.deliveries.Wait()
+
+	var lastID int64
+	for ctx.Err() == nil {
+		backlog, events, cancel := source.Subscribe(lastID)
+		for _, event := range backlog {
+			d.dispatch(ctx, event)
+			lastID = event.ID
+		}
+		lastID = d.consume(ctx, events, lastID)
+		cancel()
+	}
+}
+
+// consume dispatches events until ctx is done or the subscription is dropped and returns the last event id seen
+func (d *Dispatcher) consume(ctx context.Context, events <-chan *changes.Event, lastID int64) int64 {
+	for {
+		select {
+		case <-ctx.Done():
+			return lastID
+		case event, open := <-events:
+			if !open {
+				return lastID
+			}
+			d.dispatch(ctx, event)
+			lastID = event.ID
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event *changes.Event) {
+	subscriptions, err := d.store.List()
+	if err != nil {
+		d.ll.Println("error listing webhook subscriptions:", err)
+		return
+	}
+	var body []byte
+	for _, subscription := range subscriptions {
+		if !filterOf(subscription).Match(event) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(event); err != nil {
+				d.ll.Println("error encoding webhook event:", err)
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case d.slots <- struct{}{}:
+		}
+		d.deliveries.Add(1)
+		go func(subscription *webhook.Subscription) {
+			defer func() {
+				<-d.slots
+				d.deliveries.Done()
+			}()
+			if err := d.deliver(ctx, subscription, event, body); err != nil && ctx.Err() == nil {
+				d.ll.Printf("error delivering event %d to webhook %s: %v", event.ID, subscription.ID, err)
+			}
+		}(subscription)
+	}
+}
+
+// deliver posts body to the subscription retrying with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, subscription *webhook.Subscription, event *changes.Event, body []byte) error {
+	policy := d.Retry
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := policy.InitialBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		retryable, err = d.send(ctx, subscription, event, body)
+		if err == nil || !retryable || attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// send makes a single delivery attempt and reports whether a failure is worth retrying
+func (d *Dispatcher) send(ctx context.Context, subscription *webhook.Subscription, event *changes.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", jsonContentType)
+	req.Header.Set(EventHeader, string(event.Type))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(event.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, httpclient.ErrNonPublicAddress), err
+	}
+	defer resp.Body.Close()
+	// Draining the body lets the connection be reused
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+}
+
+func filterOf(subscription *webhook.Subscription) *changes.Filter {
+	return &changes.Filter{
+		RegionIDs:       subscription.RegionIDs,
+		NeighborhoodIDs: subscription.NeighborhoodIDs,
+		UnitIDs:         subscription.UnitIDs,
+		Vaccines:        subscription.Vaccines,
+	}
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,195 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/changes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/clients/httpclient"
+	deps "github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/dependenciesfakes"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/prefeitura"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/dependencies/webhook"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/domain"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/poller"
+	"github.com/hugocorbucci/onde-2a-dose-backend/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ webhooks.EventSource = &changes.Log{}
+
+var discardLogger = log.New(ioutil.Discard, "", 0)
+
+// delivery is a request received by the test receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records deliveries and answers with the given status codes in order, then 204
+type receiver struct {
+	mutex      sync.Mutex
+	deliveries []*delivery
+	statuses   []int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.deliveries = append(r.deliveries, &delivery{header: req.Header, body: body})
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []*delivery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*delivery(nil), r.deliveries...)
+}
+
+// startDispatcher runs a dispatcher for subscriptions and returns the change log feeding it
+func startDispatcher(t *testing.T, client deps.HTTPClient, subscriptions ...*webhook.Subscription) *changes.Log {
+	store := &dependenciesfakes.FakeSubscriptionStore{}
+	store.ListReturns(subscriptions, nil)
+	dispatcher := webhooks.NewDispatcher(store, client, discardLogger)
+	dispatcher.Retry = webhooks.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	changeLog := changes.NewLog(&changes.Detector{}, time.Hour)
+	changeLog.Record(context.Background(), &poller.Snapshot{FetchedAt: time.Now()})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx, changeLog)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return changeLog
+}
+
+func addUnits(changeLog *changes.Log, units ...*prefeitura.DeOlhoNaFilaUnit) {
+	changeLog.Record(context.Background(), &poller.Snapshot{FetchedAt: time.Now(), Units: units})
+}
+
+func TestDispatcher_DeliversSignedMatchingEvents(t *testing.T) {
+	r := &receiver{}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	changeLog := startDispatcher(t, srv.Client(), &webhook.Subscription{
+		ID:              "a",
+		URL:             srv.URL,
+		Secret:          "s3cr3t",
+		NeighborhoodIDs: []int{7},
+		Vaccines:        []domain.Vaccine{domain.VaccinePfizer},
+	})
+
+	addUnits(changeLog,
+		&prefeitura.DeOlhoNaFilaUnit{IDStr: "1", NeighborhoodIDStr: "7", PfizerStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"},
+		&prefeitura.DeOlhoNaFilaUnit{IDStr: "2", NeighborhoodIDStr: "7", PfizerStr: "0", LastUpdatedAtStr: "2021-08-11 14:00:00.000"},
+		&prefeitura.DeOlhoNaFilaUnit{IDStr: "3", NeighborhoodIDStr: "8", PfizerStr: "1", LastUpdatedAtStr: "2021-08-11 14:00:00.000"},
+	)
+
+	require.Eventually(t, func() bool { return len(r.received()) >= 1 }, time.Second, time.Millisecond, "expected a delivery")
+	time.Sleep(20 * time.Millisecond)
+	deliveries := r.received()
+	require.Len(t, deliveries, 1, "expected only the matching event to be delivered")
+	d := deliveries[0]
+	assert.Equal(t, "unit_added", d.header.Get(webhooks.EventHeader), "expected event header to match")
+	assert.Equal(t, strconv.FormatInt(changeLog.Since(time.Time{})[0].ID, 10), d.header.Get(webhooks.DeliveryHeader), "expected delivery header to match")
+	assert.True(t, webhooks.Verify("s3cr3t", d.body, d.header.Get(webhooks.SignatureHeader)), "expected signature to be valid")
+	assert.Contains(t, string(d.body), `"unit_id":1`, "expected body to hold the event")
+}
+
+func TestDispatcher_RetriesServerErrors(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	changeLog := startDispatcher(t, srv.Client(), &webhook.Subscription{ID: "a", URL: srv.URL})
+
+	addUnits(changeLog, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1"})
+
+	require.Eventually(t, func() bool { return len(r.received()) == 3 }, time.Second, time.Millisecond, "expected delivery to be retried until it succeeds")
+	deliveries := r.received()
+	assert.Equal(t, deliveries[0].body, deliveries[2].body, "expected the same body to be retried")
+}
+
+func TestDispatcher_DoesNotRetryClientErrors(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusGone}}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	changeLog := startDispatcher(t, srv.Client(), &webhook.Subscription{ID: "a", URL: srv.URL})
+
+	addUnits(changeLog, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1"})
+
+	require.Eventually(t, func() bool { return len(r.received()) >= 1 }, time.Second, time.Millisecond, "expected a delivery")
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, r.received(), 1, "expected no retry")
+}
+
+func TestDispatcher_DoesNotRetryNonPublicAddresses(t *testing.T) {
+	client := &dependenciesfakes.FakeHTTPClient{}
+	client.DoReturns(nil, fmt.Errorf("dial tcp: %w: 10.0.0.5", httpclient.ErrNonPublicAddress))
+	changeLog := startDispatcher(t, client, &webhook.Subscription{ID: "a", URL: "https://rebinding.example.org"})
+
+	addUnits(changeLog, &prefeitura.DeOlhoNaFilaUnit{IDStr: "1"})
+
+	require.Eventually(t, func() bool { return client.DoCallCount() >= 1 }, time.Second, time.Millisecond, "expected a delivery attempt")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, client.DoCallCount(), "expected no retry")
+}
+
+func TestCheckHost_RejectsNonPublicAddresses(t *testing.T) {
+	resolver := &dependenciesfakes.FakeResolver{}
+	resolver.LookupIPAddrStub = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "public.example.org":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		case "mixed.example.org":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("192.168.1.1")}}, nil
+		default:
+			return nil, errors.New("no such host")
+		}
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, webhooks.CheckHost(ctx, resolver, "public.example.org"), "expected public host to be accepted")
+	assert.NoError(t, webhooks.CheckHost(ctx, resolver, "93.184.216.34"), "expected public address to be accepted")
+	assert.ErrorIs(t, webhooks.CheckHost(ctx, resolver, "mixed.example.org"), httpclient.ErrNonPublicAddress, "expected any private address to be rejected")
+	assert.ErrorIs(t, webhooks.CheckHost(ctx, resolver, "169.254.169.254"), httpclient.ErrNonPublicAddress, "expected link-local address to be rejected")
+	assert.ErrorIs(t, webhooks.CheckHost(ctx, resolver, "::1"), httpclient.ErrNonPublicAddress, "expected loopback address to be rejected")
+	err := webhooks.CheckHost(ctx, resolver, "unknown.example.org")
+	assert.Error(t, err, "expected lookup error")
+	assert.NotErrorIs(t, err, httpclient.ErrNonPublicAddress, "expected lookup error to match")
+}
+
+func TestRegister_AssignsIdentityAndSecret(t *testing.T) {
+	store := &dependenciesfakes.FakeSubscriptionStore{}
+	subscription := &webhook.Subscription{URL: "http://example.com"}
+
+	require.NoError(t, webhooks.Register(store, subscription), "expected error to match")
+	assert.NotEmpty(t, subscription.ID, "expected id to be assigned")
+	assert.NotEmpty(t, subscription.Secret, "expected secret to be generated")
+	assert.False(t, subscription.CreatedAt.IsZero(), "expected creation time to be set")
+	require.Equal(t, 1, store.SaveCallCount(), "expected subscription to be saved")
+
+	withSecret := &webhook.Subscription{URL: "http://example.com", Secret: "mine"}
+	require.NoError(t, webhooks.Register(store, withSecret), "expected error to match")
+	assert.Equal(t, "mine", withSecret.Secret, "expected given secret to be kept")
+}